@@ -0,0 +1,75 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+)
+
+// barBackend runs the bars of a theme. Themes pick their backend with
+// 'backend' in theme.yml, polybar is the default.
+type barBackend interface {
+	// ConfigFile is the name of the bar config in a theme's directory.
+	ConfigFile() string
+	// Bars returns the bars defined in a bar config, in order.
+	Bars(path string) ([]string, error)
+	// HasMonitor reports whether a bar that isn't assigned to a role has a
+	// monitor to go on.
+	HasMonitor(path, bar string, env map[string]string) bool
+	// Check returns the problems of the bars that would stop them from
+	// loading. fonts is false when fonts can't be checked.
+	Check(path string, bars []string, fonts bool) []themeProblem
+	// Gaps returns the room the bars take on each output, for bars that i3
+	// doesn't make room for itself.
+	Gaps(path string, instances []barInstance, env map[string]string) (map[string]edgeGaps, error)
+	// Launch starts the bar instances and returns once they are up.
+	Launch(theme Theme, path string, instances []barInstance, env map[string]string) error
+	// Running returns the bar instances the backend runs.
+	Running() ([]barInstance, error)
+	// Reload makes the running bars read their config again.
+	Reload(path string, env map[string]string) error
+	// Stop stops the bars the backend started, leaving other bars alone.
+	Stop() error
+}
+
+const defaultBarBackend = "polybar"
+
+var barBackends = map[string]barBackend{
+	"polybar": polybarBackend{},
+	"i3bar":   i3barBackend{},
+}
+
+func barBackendNames() []string {
+	var names []string
+	for name := range barBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// themeBackend returns the backend that runs a theme's bars.
+func themeBackend(t Theme) (barBackend, error) {
+	name := t.Backend
+	if name == "" {
+		name = defaultBarBackend
+	}
+	b, ok := barBackends[name]
+	if !ok {
+		return nil, fmt.Errorf("theme \"%s\" uses unknown backend \"%s\", expected one of: %v", t.Name, name, barBackendNames())
+	}
+	return b, nil
+}
+
+// stopBars stops the bars of every backend, so switching to a theme with
+// another backend doesn't leave the old bars running.
+func stopBars() error {
+	var firstErr error
+	for _, name := range barBackendNames() {
+		if err := barBackends[name].Stop(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,251 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/patrick-motard/dot/lib"
+	"go.i3wm.org/i3"
+)
+
+// i3barBackend runs i3bar bars, e.g. with i3status-rust or i3blocks. i3 starts
+// an i3bar for every bar block in its config, so dot writes the theme's bars
+// to a file the i3 config includes and reloads i3.
+type i3barBackend struct{}
+
+// i3barInclude is the file with the bars of the current theme. The i3 config
+// has to include it: include ~/.local/state/dot/i3bar.conf
+func i3barInclude() string {
+	return filepath.Join(stateDir(), "i3bar.conf")
+}
+
+func (i3barBackend) ConfigFile() string {
+	return "i3bar.conf"
+}
+
+func (i3barBackend) Bars(path string) ([]string, error) {
+	c, err := lib.ParseI3barConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	var bars []string
+	for _, b := range c.Bars {
+		bars = append(bars, b.ID)
+	}
+	return bars, nil
+}
+
+func (i3barBackend) HasMonitor(path, bar string, env map[string]string) bool {
+	c, err := lib.ParseI3barConfig(path)
+	if err != nil {
+		return true
+	}
+	b, ok := c.Bar(bar)
+	if !ok {
+		return true
+	}
+	output, ok := b.Get("output")
+	return !ok || expandBarVars(output, env) != ""
+}
+
+func (i3barBackend) Check(path string, bars []string, fonts bool) []themeProblem {
+	c, err := lib.ParseI3barConfig(path)
+	if err != nil {
+		return []themeProblem{{Kind: problemConfig, Pos: path, Message: err.Error()}}
+	}
+	env := map[string]string{"THEME_DIR": filepath.Dir(path)}
+	var problems []themeProblem
+	for _, id := range bars {
+		b, ok := c.Bar(id)
+		if !ok {
+			continue
+		}
+		if command, ok := b.Get("status_command"); ok {
+			fields := strings.Fields(expandBarVars(command, env))
+			if len(fields) > 1 && fields[0] == "exec" {
+				fields = fields[1:]
+			}
+			if len(fields) == 0 {
+				problems = append(problems, themeProblem{problemScript, b.Pos(), fmt.Sprintf("status_command of bar \"%s\" is empty", id)})
+			} else if err := checkExecutable(fields[0]); err != nil {
+				problems = append(problems, themeProblem{problemScript, b.Pos(), fmt.Sprintf("status_command of bar \"%s\": %s", id, err)})
+			}
+		}
+		if font, ok := b.Get("font"); ok && fonts {
+			if family := pangoFamily(font); family != "" {
+				if err := checkFont(family); err != nil {
+					problems = append(problems, themeProblem{problemFont, b.Pos(), err.Error()})
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// pangoFamily returns the first family of an i3 pango font, X core fonts are
+// not checked.
+// example: pango:Iosevka, FontAwesome 10 -> Iosevka
+func pangoFamily(font string) string {
+	if !strings.HasPrefix(font, "pango:") {
+		return ""
+	}
+	family := strings.TrimSpace(strings.SplitN(strings.TrimPrefix(font, "pango:"), ",", 2)[0])
+	fields := strings.Fields(family)
+	if len(fields) > 1 {
+		if _, err := fmt.Sscanf(fields[len(fields)-1], "%f", new(float64)); err == nil {
+			fields = fields[:len(fields)-1]
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// Gaps returns no gaps, i3 makes room for i3bar itself.
+func (i3barBackend) Gaps(path string, instances []barInstance, env map[string]string) (map[string]edgeGaps, error) {
+	return nil, nil
+}
+
+func (b i3barBackend) Launch(theme Theme, path string, instances []barInstance, env map[string]string) error {
+	if err := writeI3barInclude(theme.Name, path, instances, env); err != nil {
+		return err
+	}
+	if err := reloadI3KeepGaps(); err != nil {
+		return fmt.Errorf("reloading i3 failed: %s", err)
+	}
+	return waitForI3bars(instances)
+}
+
+// reloadI3KeepGaps reloads i3, which restarts i3bar. The reload resets the
+// gaps, so the gaps dot set are set again.
+func reloadI3KeepGaps() error {
+	if _, err := i3.RunCommand("reload"); err != nil {
+		return err
+	}
+	return restoreI3Gaps()
+}
+
+// waitForI3bars checks that i3 picked up the bars after it was reloaded.
+func waitForI3bars(instances []barInstance) error {
+	deadline := time.Now().Add(barStartTimeout)
+	for {
+		ids, err := i3.GetBarIDs()
+		if err != nil {
+			return err
+		}
+		known := map[string]bool{}
+		for _, id := range ids {
+			known[id] = true
+		}
+		var missing []string
+		for _, i := range instances {
+			if !known[i.String()] {
+				missing = append(missing, i.String())
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("i3 doesn't know bars %s, does your i3 config have 'include %s'?", strings.Join(missing, ", "), i3barInclude())
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func (i3barBackend) Running() ([]barInstance, error) {
+	if _, err := os.Stat(i3barInclude()); os.IsNotExist(err) {
+		return nil, nil
+	}
+	c, err := lib.ParseI3barConfig(i3barInclude())
+	if err != nil {
+		return nil, err
+	}
+	var instances []barInstance
+	for _, b := range c.Bars {
+		instances = append(instances, parseBarInstance(b.ID))
+	}
+	return instances, nil
+}
+
+// Reload writes the running bars from the theme's config again and reloads
+// i3, which restarts its bars.
+func (b i3barBackend) Reload(path string, env map[string]string) error {
+	instances, err := b.Running()
+	if err != nil {
+		return err
+	}
+	if err := writeI3barInclude(Config.Polybar.Theme, path, instances, env); err != nil {
+		return err
+	}
+	return reloadI3KeepGaps()
+}
+
+func (b i3barBackend) Stop() error {
+	instances, err := b.Running()
+	if err != nil || len(instances) == 0 {
+		return err
+	}
+	log.Infof("Stopping %d i3bar(s)", len(instances))
+	if err := ioutil.WriteFile(i3barInclude(), nil, 0644); err != nil {
+		return err
+	}
+	return reloadI3KeepGaps()
+}
+
+// writeI3barInclude writes a bar block for every instance. Instances on a
+// monitor get an 'output' line, and ${MONITOR}, ${THEME_DIR} and the other
+// variables dot hands to bars are replaced.
+func writeI3barInclude(theme, path string, instances []barInstance, env map[string]string) error {
+	c, err := lib.ParseI3barConfig(path)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Bars of theme %s, written by dot from %s. Changes are overwritten.\n", theme, path)
+	for _, i := range instances {
+		b, ok := c.Bar(i.Bar)
+		if !ok {
+			return fmt.Errorf("bar \"%s\" is not defined in %s", i.Bar, path)
+		}
+		vars := map[string]string{"MONITOR": i.Monitor, "THEME_DIR": filepath.Dir(path)}
+		for k, v := range env {
+			vars[k] = v
+		}
+		vars["MONITOR"] = i.Monitor
+		fmt.Fprintf(&buf, "\nbar {\n\tid %s\n", i)
+		if i.Monitor != "" {
+			fmt.Fprintf(&buf, "\toutput %s\n", i.Monitor)
+		}
+		for _, line := range b.Lines {
+			if i.Monitor != "" && strings.HasPrefix(strings.TrimSpace(line), "output ") {
+				continue
+			}
+			fmt.Fprintln(&buf, expandBarVars(line, vars))
+		}
+		fmt.Fprintln(&buf, "}")
+	}
+	if err := os.MkdirAll(filepath.Dir(i3barInclude()), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(i3barInclude(), buf.Bytes(), 0644)
+}
+
+var barVarRe = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// expandBarVars replaces ${NAME} with the variables dot hands to bars. Other
+// variables, like i3's $mod, are left alone.
+func expandBarVars(s string, vars map[string]string) string {
+	return barVarRe.ReplaceAllStringFunc(s, func(m string) string {
+		if v, ok := vars[m[2:len(m)-1]]; ok {
+			return v
+		}
+		return m
+	})
+}
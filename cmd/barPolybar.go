@@ -0,0 +1,126 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+)
+
+// polybarBackend runs polybar bars under dot's supervisor.
+type polybarBackend struct{}
+
+func (polybarBackend) ConfigFile() string {
+	return "config"
+}
+
+func (polybarBackend) Bars(path string) ([]string, error) {
+	c, err := loadPolybarConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return c.Bars(), nil
+}
+
+func (polybarBackend) HasMonitor(path, bar string, env map[string]string) bool {
+	c, err := loadPolybarConfig(path)
+	if err != nil {
+		return true
+	}
+	return hasMonitor(c, bar, env)
+}
+
+func (polybarBackend) Check(path string, bars []string, fonts bool) []themeProblem {
+	c, err := loadPolybarConfig(path)
+	if err != nil {
+		return []themeProblem{{Kind: problemConfig, Pos: path, Message: err.Error()}}
+	}
+	var problems []themeProblem
+	checked := map[string]bool{}
+	for _, bar := range bars {
+		section := "bar/" + bar
+		if _, ok := c.Section(section); !ok {
+			continue
+		}
+		problems = append(problems, checkBarModules(c, section, checked)...)
+		if fonts {
+			problems = append(problems, checkBarFonts(c, section)...)
+		}
+	}
+	return problems
+}
+
+func (polybarBackend) Gaps(path string, instances []barInstance, env map[string]string) (map[string]edgeGaps, error) {
+	c, err := loadPolybarConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return barGaps(c, instances, env)
+}
+
+func (polybarBackend) Launch(theme Theme, path string, instances []barInstance, env map[string]string) error {
+	c, err := loadPolybarConfig(path)
+	if err != nil {
+		return err
+	}
+	// create a new array of env vars, appending the current environment
+	// with the env vars we hand to polybar
+	newEnv := os.Environ()
+	for k, v := range env {
+		newEnv = append(newEnv, fmt.Sprintf("%s=%s", k, v))
+	}
+	var names []string
+	for _, i := range instances {
+		names = append(names, i.String())
+	}
+	// start all the bars in the background and return once they are up
+	if err := startPolybar(newEnv, theme.Name, names); err != nil {
+		return err
+	}
+	return waitForBarWindows(c, instances, env)
+}
+
+func (polybarBackend) Running() ([]barInstance, error) {
+	s, err := readPolybarState()
+	if err != nil || !processAlive(s.Supervisor) {
+		return nil, err
+	}
+	var instances []barInstance
+	for _, name := range s.barNames() {
+		instances = append(instances, parseBarInstance(name))
+	}
+	return instances, nil
+}
+
+// Reload restarts the running bars. Bars with IPC enabled restart themselves,
+// others are restarted by the supervisor.
+func (polybarBackend) Reload(path string, env map[string]string) error {
+	s, err := readPolybarState()
+	if err != nil {
+		return err
+	}
+	c, err := loadPolybarConfig(path)
+	if err != nil {
+		return err
+	}
+	for _, name := range s.barNames() {
+		b := s.Bars[name]
+		if hasPolybarIPC(c, parseBarInstance(name).Bar) && processAlive(b.Pid) {
+			err := sendPolybarMessage(b.Pid, polybarCommand("restart"))
+			if err == nil {
+				log.Infof("Reloaded bar '%s' through IPC", name)
+				continue
+			}
+			log.Warnf("Failed to reload bar '%s' through IPC: %s", name, err)
+		}
+		if err := restartBar(name); err != nil {
+			return err
+		}
+		log.Infof("Restarted bar '%s'", name)
+	}
+	return nil
+}
+
+func (polybarBackend) Stop() error {
+	return stopPolybar()
+}
@@ -4,6 +4,8 @@ package cmd
 
 import (
 	"fmt"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
@@ -44,3 +46,12 @@ func init() {
 	displaysCmd.Flags().BoolVarP(&_rightDisplay, "get-right", "r", false, "Get the name of your right display.")
 	displaysCmd.Flags().BoolVarP(&_primaryDisplay, "get-primary", "p", false, "Get the name of your primary display.")
 }
+
+// displayProfile returns the key a display profile goes by in
+// current_settings.yml and theme.yml, e.g. Home_DP-4.sh -> home_dp-4. viper
+// lowercases keys and splits them at dots, so the extension of the script is
+// dropped and other dots become underscores.
+func displayProfile(script string) string {
+	name := strings.TrimSuffix(script, filepath.Ext(script))
+	return strings.ToLower(strings.Replace(name, ".", "_", -1))
+}
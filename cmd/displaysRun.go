@@ -23,9 +23,17 @@ var displaysRunCmd = &cobra.Command{
 			}
 			viper.Set("displays.current", Name)
 			viper.WriteConfig()
+			Config.Displays.Current = Name
+			notifyPolybar("displays")
+			reapplyWallpapers()
+			rebuildI3Config()
 			return
 		}
-		RunDisplaysScript(viper.GetString("displays.current"))
+		if err := RunDisplaysScript(viper.GetString("displays.current")); err == nil {
+			notifyPolybar("displays")
+			reapplyWallpapers()
+			rebuildI3Config()
+		}
 	},
 }
 
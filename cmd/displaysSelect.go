@@ -71,6 +71,10 @@ Dot will remember what you chose, even if you log out or reboot.
 		}
 		viper.Set("displays.current", selection)
 		viper.WriteConfig()
+		Config.Displays.Current = selection
+		notifyPolybar("displays")
+		reapplyWallpapers()
+		rebuildI3Config()
 	},
 }
 
@@ -0,0 +1,321 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.i3wm.org/i3"
+)
+
+// i3AppearanceInclude is the file with the i3 settings of the current theme.
+// The i3 config has to include it after its own settings, so the theme's win:
+// include ~/.local/state/dot/i3-appearance.conf
+func i3AppearanceInclude() string {
+	return filepath.Join(stateDir(), "i3-appearance.conf")
+}
+
+// i3ColorClasses are the window classes of client.* colors, in the order they
+// are written.
+var i3ColorClasses = []string{"focused", "focused_inactive", "unfocused", "urgent", "placeholder", "background"}
+
+// i3PaletteColors are the client.* colors of a theme with a palette, for the
+// classes the theme doesn't set colors of.
+var i3PaletteColors = map[string]string{
+	"focused":          "accent accent background accent accent",
+	"focused_inactive": "background background foreground background background",
+	"unfocused":        "background background foreground background background",
+	"urgent":           "urgent urgent background urgent urgent",
+	"placeholder":      "background background foreground background background",
+	"background":       "background",
+}
+
+// i3DefaultBorder is the border of windows in an i3 config that doesn't set one.
+const i3DefaultBorder = "normal 2"
+
+// mergeI3Appearance returns base with every setting that is set in override
+// replaced. Colors are merged by class, workspace gaps by workspace.
+func mergeI3Appearance(base, override I3Appearance) I3Appearance {
+	if override.Border != "" {
+		base.Border = override.Border
+	}
+	if override.BorderWidth != 0 {
+		base.BorderWidth = override.BorderWidth
+	}
+	if override.Font != "" {
+		base.Font = override.Font
+	}
+	if override.SmartGaps != "" {
+		base.SmartGaps = override.SmartGaps
+	}
+	if override.SmartBorders != "" {
+		base.SmartBorders = override.SmartBorders
+	}
+	if len(override.Colors) > 0 {
+		colors := map[string]string{}
+		for class, c := range base.Colors {
+			colors[class] = c
+		}
+		for class, c := range override.Colors {
+			colors[class] = c
+		}
+		base.Colors = colors
+	}
+	if len(override.WorkspaceGaps) > 0 {
+		gaps := map[string]I3Gaps{}
+		for ws, g := range base.WorkspaceGaps {
+			gaps[ws] = g
+		}
+		for ws, g := range override.WorkspaceGaps {
+			gaps[ws] = mergeGaps(gaps[ws], g)
+		}
+		base.WorkspaceGaps = gaps
+	}
+	return base
+}
+
+// i3Switch reads an on/off setting. yaml reads a bare on or off as a boolean,
+// which viper hands over as 1 or 0.
+func i3Switch(value string) string {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes":
+		return "on"
+	case "0", "false", "no":
+		return "off"
+	}
+	return strings.ToLower(value)
+}
+
+// i3Border returns the border of the appearance as i3 writes it, e.g. pixel 2.
+func i3Border(a I3Appearance) string {
+	if a.BorderWidth > 0 && a.Border != "none" {
+		return fmt.Sprintf("%s %d", a.Border, a.BorderWidth)
+	}
+	return a.Border
+}
+
+// i3Colors returns a client.* color line of the theme with the colors that
+// are named after palette colors filled in. Classes the theme doesn't set
+// colors of get the colors of its palette.
+func i3Colors(t Theme, class string) (string, error) {
+	colors, ok := t.I3.Colors[class]
+	if !ok {
+		colors = i3PaletteColors[class]
+	}
+	fields := strings.Fields(colors)
+	if class == "background" && len(fields) != 1 {
+		return "", fmt.Errorf("i3 color \"background\" takes 1 color, got %d", len(fields))
+	}
+	if class != "background" && (len(fields) < 3 || len(fields) > 5) {
+		return "", fmt.Errorf("i3 color \"%s\" takes 3 to 5 colors (border background text indicator child_border), got %d", class, len(fields))
+	}
+	named := map[string]string{}
+	if hasPalette(t) {
+		if p, err := resolvePalette(t); err == nil {
+			named["background"], named["foreground"] = p.Background, p.Foreground
+			named["accent"], named["urgent"] = p.Accent, p.Urgent
+			for i, c := range p.Colors {
+				named["color"+strconv.Itoa(i)] = c
+			}
+		}
+	}
+	for i, f := range fields {
+		if c, ok := named[strings.ToLower(f)]; ok {
+			fields[i] = c
+		} else if !paletteColorRe.MatchString(f) {
+			return "", fmt.Errorf("i3 color \"%s\" has invalid color \"%s\", expected #rrggbb or a color of the theme's palette", class, f)
+		}
+	}
+	return strings.Join(fields, " "), nil
+}
+
+// i3SettingError is a problem with an i3 setting of a theme. Key is where the
+// setting is under i3 in theme.yml, e.g. colors.focused.
+type i3SettingError struct {
+	Key string
+	Err error
+}
+
+func (e i3SettingError) Error() string {
+	return e.Err.Error()
+}
+
+// checkI3Appearance returns what is wrong with the theme's i3 settings.
+func checkI3Appearance(t Theme) []i3SettingError {
+	a := t.I3
+	var errs []i3SettingError
+	switch a.Border {
+	case "", "normal", "pixel", "none":
+	default:
+		errs = append(errs, i3SettingError{"border", fmt.Errorf("i3 border \"%s\" is not normal, pixel or none", a.Border)})
+	}
+	if a.BorderWidth < 0 {
+		errs = append(errs, i3SettingError{"border_width", fmt.Errorf("i3 border_width %d is negative", a.BorderWidth)})
+	} else if a.BorderWidth > 0 && a.Border == "" {
+		errs = append(errs, i3SettingError{"border_width", fmt.Errorf("i3 border_width needs a border, e.g. 'border: pixel'")})
+	}
+	switch i3Switch(a.SmartGaps) {
+	case "", "on", "off", "inverse_outer":
+	default:
+		errs = append(errs, i3SettingError{"smart_gaps", fmt.Errorf("i3 smart_gaps \"%s\" is not on, off or inverse_outer", a.SmartGaps)})
+	}
+	switch i3Switch(a.SmartBorders) {
+	case "", "on", "off", "no_gaps":
+	default:
+		errs = append(errs, i3SettingError{"smart_borders", fmt.Errorf("i3 smart_borders \"%s\" is not on, off or no_gaps", a.SmartBorders)})
+	}
+	var classes []string
+	for class := range a.Colors {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		known := false
+		for _, c := range i3ColorClasses {
+			known = known || c == class
+		}
+		if !known {
+			errs = append(errs, i3SettingError{"colors." + class, fmt.Errorf("i3 color \"%s\" is not one of %s", class, strings.Join(i3ColorClasses, ", "))})
+		} else if _, err := i3Colors(t, class); err != nil {
+			errs = append(errs, i3SettingError{"colors." + class, err})
+		}
+	}
+	var workspaces []string
+	for ws := range a.WorkspaceGaps {
+		workspaces = append(workspaces, ws)
+	}
+	sort.Strings(workspaces)
+	for _, ws := range workspaces {
+		g := a.WorkspaceGaps[ws]
+		for _, size := range []string{g.Inner, g.Outer, g.Top, g.Right, g.Bottom, g.Left} {
+			if _, err := strconv.Atoi(size); size != "" && err != nil {
+				errs = append(errs, i3SettingError{"workspace_gaps." + ws, fmt.Errorf("gap \"%s\" of workspace \"%s\" is not a number", size, ws)})
+			}
+		}
+	}
+	return errs
+}
+
+// writeI3Appearance returns the include file with the theme's i3 settings.
+// Settings the theme doesn't set are left out, so i3 goes back to its own.
+func writeI3Appearance(t Theme) ([]byte, error) {
+	a := t.I3
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "# i3 settings of the current theme, written by dot. Changes are overwritten.")
+	if a.Border != "" {
+		fmt.Fprintf(&buf, "default_border %s\ndefault_floating_border %s\n", i3Border(a), i3Border(a))
+	}
+	if a.Font != "" {
+		fmt.Fprintf(&buf, "font %s\n", a.Font)
+	}
+	if a.SmartGaps != "" {
+		fmt.Fprintf(&buf, "smart_gaps %s\n", i3Switch(a.SmartGaps))
+	}
+	if a.SmartBorders != "" {
+		fmt.Fprintf(&buf, "smart_borders %s\n", i3Switch(a.SmartBorders))
+	}
+	for _, class := range i3ColorClasses {
+		if _, ok := a.Colors[class]; !ok && !hasPalette(t) {
+			continue
+		}
+		colors, err := i3Colors(t, class)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "client.%s %s\n", class, colors)
+	}
+	var workspaces []string
+	for ws := range a.WorkspaceGaps {
+		workspaces = append(workspaces, ws)
+	}
+	sort.Strings(workspaces)
+	for _, ws := range workspaces {
+		g := a.WorkspaceGaps[ws]
+		sides := []string{"inner", "outer", "top", "right", "bottom", "left"}
+		for i, size := range []string{g.Inner, g.Outer, g.Top, g.Right, g.Bottom, g.Left} {
+			if size != "" {
+				fmt.Fprintf(&buf, "workspace %s gaps %s %s\n", strconv.Quote(ws), sides[i], size)
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// applyI3Appearance writes the theme's i3 settings to the include file and
+// applies them. i3 has no commands for most of them, so it is reloaded when
+// the file changes. The border of open windows is set over IPC, and set back
+// to the border of the i3 config when the previous theme had one.
+func applyI3Appearance(t Theme) error {
+	if errs := checkI3Appearance(t); len(errs) > 0 {
+		return errs[0]
+	}
+	data, err := writeI3Appearance(t)
+	if err != nil {
+		return err
+	}
+	path := i3AppearanceInclude()
+	old, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if bytes.Equal(old, data) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	log.Infof("Wrote the i3 settings of theme \"%s\" to %s", t.Name, path)
+	if _, err := i3.RunCommand("reload"); err != nil {
+		return err
+	}
+
+	border, oldBorder := includeBorder(data), includeBorder(old)
+	if border == oldBorder {
+		return nil
+	}
+	if border == "" {
+		border = i3ConfigBorder()
+	}
+	log.Infof("Setting the border of open windows to \"%s\"", border)
+	_, err = i3.RunCommand("[all] border " + border)
+	return err
+}
+
+// includeBorder returns the default_border of an include file dot wrote.
+func includeBorder(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "default_border ") {
+			return strings.TrimPrefix(line, "default_border ")
+		}
+	}
+	return ""
+}
+
+// i3ConfigBorder returns the border the i3 config gives windows.
+func i3ConfigBorder() string {
+	c, err := i3.GetConfig()
+	if err != nil {
+		return i3DefaultBorder
+	}
+	border := i3DefaultBorder
+	scanner := bufio.NewScanner(strings.NewReader(c.Config))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// new_window is what older versions of i3 call default_border
+		if len(fields) > 1 && (fields[0] == "default_border" || fields[0] == "new_window") {
+			border = strings.Join(fields[1:], " ")
+		}
+	}
+	return border
+}
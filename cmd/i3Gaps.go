@@ -0,0 +1,288 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/patrick-motard/dot/lib"
+	"github.com/spf13/cobra"
+	"go.i3wm.org/i3"
+)
+
+var i3GapsCmd = &cobra.Command{
+	Use:   "gaps",
+	Short: "Show and change the i3 gaps.",
+	Long: `dot sets the i3 gaps when it loads a theme: the theme's gaps, room for its bars,
+or the default gaps. The defaults are the gaps directives of the i3 config, and
+i3_wm.default_gaps in current_settings.yml for the gaps the i3 config doesn't set.
+
+Gaps changed with 'dot i3 gaps set', inc, dec and toggle are kept in dot's state
+and set again after dot loads a theme, until 'dot i3 gaps reset'. Bind them to
+keys instead of i3's own gaps commands:
+
+bindsym $mod+plus exec --no-startup-id dot i3 gaps inc inner 5
+bindsym $mod+minus exec --no-startup-id dot i3 gaps dec inner 5
+bindsym $mod+g exec --no-startup-id dot i3 gaps toggle outer --workspace all`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+func init() {
+	i3Cmd.AddCommand(i3GapsCmd)
+}
+
+// i3GapSides are the gaps dot knows, in the order they are shown.
+var i3GapSides = []string{"inner", "top", "right", "bottom", "left"}
+
+// i3GapTypes maps the gaps i3 commands and directives take to the sides they
+// set.
+var i3GapTypes = map[string][]string{
+	"inner":      {"inner"},
+	"outer":      {"top", "right", "bottom", "left"},
+	"horizontal": {"right", "left"},
+	"vertical":   {"top", "bottom"},
+	"top":        {"top"},
+	"right":      {"right"},
+	"bottom":     {"bottom"},
+	"left":       {"left"},
+}
+
+// i3Gap is the size of a gap and where it was set.
+type i3Gap struct {
+	Size   string `json:"size"`
+	Source string `json:"source"`
+}
+
+// i3ConfigPath returns the i3 config: i3_wm.settings_file, the config i3 has
+// loaded, or ~/.config/i3/config.
+func i3ConfigPath() string {
+	if Config.I3wm.SettingsFile != "" {
+		if filepath.IsAbs(Config.I3wm.SettingsFile) {
+			return Config.I3wm.SettingsFile
+		}
+		return filepath.Join(Home, Config.I3wm.SettingsFile)
+	}
+	if v, err := i3.GetVersion(); err == nil && v.LoadedConfigFileName != "" {
+		return v.LoadedConfigFileName
+	}
+	return filepath.Join(Home, ".config", "i3", "config")
+}
+
+// i3ConfigGaps returns the gaps the gaps directives of the i3 config set, by
+// side. Like in i3, later directives win, and outer, horizontal and vertical
+// set several sides at once.
+func i3ConfigGaps(path string) (map[string]i3Gap, error) {
+	c, err := lib.ParseI3Config(path)
+	if err != nil {
+		return nil, err
+	}
+	gaps := map[string]i3Gap{}
+	for _, l := range c.Directives("gaps") {
+		fields := l.Fields()
+		if len(fields) != 3 {
+			log.Warnf("%s: skipping gaps directive \"%s\"", l.Pos(), l.Text)
+			continue
+		}
+		size := strings.TrimSuffix(fields[2], "px")
+		if _, err := strconv.Atoi(size); err != nil {
+			log.Warnf("%s: gap \"%s\" is not a number", l.Pos(), fields[2])
+			continue
+		}
+		for _, side := range i3GapTypes[fields[1]] {
+			gaps[side] = i3Gap{size, l.Pos()}
+		}
+	}
+	return gaps, nil
+}
+
+// defaultI3Gaps returns the default gap of every side: the gap the i3 config
+// sets, or else the gap in i3_wm.default_gaps.
+func defaultI3Gaps() map[string]i3Gap {
+	path := i3ConfigPath()
+	gaps, err := i3ConfigGaps(path)
+	if err != nil {
+		log.Warnf("Failed to read the gaps of the i3 config: %s", err)
+		gaps = map[string]i3Gap{}
+	}
+	d := Config.I3wm.DefaultGaps
+	configured := map[string]string{"inner": d.Inner, "top": d.Top, "right": d.Right, "bottom": d.Bottom, "left": d.Left}
+	for _, side := range i3GapSides {
+		if _, ok := gaps[side]; !ok && configured[side] != "" {
+			gaps[side] = i3Gap{configured[side], "i3_wm.default_gaps"}
+		}
+	}
+	return gaps
+}
+
+// i3GapsState is what dot last set the gaps to.
+type i3GapsState struct {
+	// Gaps are the gaps of all workspaces, by side.
+	Gaps map[string]i3Gap `json:"gaps"`
+	// Outputs are the gaps of the outputs that need room for bars, by output
+	// and side.
+	Outputs map[string]map[string]int `json:"outputs,omitempty"`
+	// All and Workspaces are the gaps set with 'dot i3 gaps', of all
+	// workspaces and of single workspaces by name. They don't include the
+	// room for bars, which is added when they are set.
+	All        map[string]int            `json:"all,omitempty"`
+	Workspaces map[string]map[string]int `json:"workspaces,omitempty"`
+}
+
+func i3GapsStateFile() string {
+	return filepath.Join(stateDir(), "i3-gaps.json")
+}
+
+func readI3GapsState() (i3GapsState, error) {
+	s := i3GapsState{
+		Gaps:       map[string]i3Gap{},
+		Outputs:    map[string]map[string]int{},
+		All:        map[string]int{},
+		Workspaces: map[string]map[string]int{},
+	}
+	data, err := ioutil.ReadFile(i3GapsStateFile())
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("failed to parse %s: %s", i3GapsStateFile(), err)
+	}
+	if s.Gaps == nil {
+		s.Gaps = map[string]i3Gap{}
+	}
+	if s.Outputs == nil {
+		s.Outputs = map[string]map[string]int{}
+	}
+	if s.All == nil {
+		s.All = map[string]int{}
+	}
+	if s.Workspaces == nil {
+		s.Workspaces = map[string]map[string]int{}
+	}
+	return s, nil
+}
+
+// readI3Gaps returns the gaps dot set, or the default gaps if it hasn't set
+// any yet.
+func readI3Gaps() (i3GapsState, error) {
+	s, err := readI3GapsState()
+	if err == nil && len(s.Gaps) == 0 {
+		s.Gaps = defaultI3Gaps()
+	}
+	return s, err
+}
+
+func (s i3GapsState) write() error {
+	if err := os.MkdirAll(stateDir(), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(i3GapsStateFile(), data, 0644)
+}
+
+// baseGap returns the gap dot set a side to when it loaded the theme.
+func (s i3GapsState) baseGap(side string) int {
+	size, _ := strconv.Atoi(s.Gaps[side].Size)
+	return size
+}
+
+// room returns the room the bars on an output need at a side.
+func (s i3GapsState) room(output, side string) int {
+	if size, ok := s.Outputs[output][side]; ok {
+		return size - s.baseGap(side)
+	}
+	return 0
+}
+
+// gap returns the gap of a workspace, or of all workspaces if ws is empty,
+// without the room for bars.
+func (s i3GapsState) gap(ws, side string) int {
+	if size, ok := s.Workspaces[ws][side]; ok && ws != "" {
+		return size
+	}
+	if size, ok := s.All[side]; ok {
+		return size
+	}
+	return s.baseGap(side)
+}
+
+// setAllGaps sets a gap of all workspaces, plus the room for bars on the
+// outputs that have them.
+func (s i3GapsState) setAllGaps(side string, size int) error {
+	sizes := map[string]int{}
+	for output := range s.Outputs {
+		if room := s.room(output, side); room > 0 {
+			sizes[output] = size + room
+		}
+	}
+	return setI3Gaps(side, sizes, size)
+}
+
+// setWorkspaceGaps sets gaps of single workspaces, by workspace and side,
+// plus the room for bars. Workspaces that don't exist are skipped.
+func (s i3GapsState) setWorkspaceGaps(gaps map[string]map[string]int) error {
+	workspaces, err := i3.GetWorkspaces()
+	if err != nil {
+		return err
+	}
+	commands := map[string]string{}
+	for _, ws := range workspaces {
+		var c []string
+		for _, side := range i3GapSides {
+			if size, ok := gaps[ws.Name][side]; ok {
+				c = append(c, fmt.Sprintf("gaps %s current set %d", side, size+s.room(ws.Output, side)))
+			}
+		}
+		if len(c) > 0 {
+			commands[ws.Name] = strings.Join(c, "; ")
+		}
+	}
+	return runOnWorkspaces(workspaces, commands)
+}
+
+// applyI3GapOverrides sets the gaps set with 'dot i3 gaps' again, after dot
+// set the gaps of a theme.
+func applyI3GapOverrides(s i3GapsState) error {
+	if len(s.All) == 0 && len(s.Workspaces) == 0 {
+		return nil
+	}
+	log.Infof("Setting the gaps changed with 'dot i3 gaps' again")
+	for _, side := range i3GapSides {
+		if size, ok := s.All[side]; ok {
+			if err := s.setAllGaps(side, size); err != nil {
+				return err
+			}
+		}
+	}
+	return s.setWorkspaceGaps(s.Workspaces)
+}
+
+// restoreI3Gaps sets the gaps dot set last again, e.g. after i3 reloaded its
+// config and reset them.
+func restoreI3Gaps() error {
+	s, err := readI3GapsState()
+	if err != nil || len(s.Gaps) == 0 {
+		return err
+	}
+	for _, side := range i3GapSides {
+		if _, ok := s.Gaps[side]; ok {
+			if err := s.setAllGaps(side, s.baseGap(side)); err != nil {
+				return err
+			}
+		}
+	}
+	return applyI3GapOverrides(s)
+}
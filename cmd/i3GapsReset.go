@@ -0,0 +1,102 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var i3GapsResetCmd = &cobra.Command{
+	Use:   "reset [gap]",
+	Short: "Undo the gaps changed with 'dot i3 gaps'.",
+	Long: `Sets gaps changed with 'dot i3 gaps' back to the gaps of the theme, and forgets
+them. Resets every gap unless one is given. With --workspace all, the gaps of
+single workspaces are reset too.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sides := i3GapSides
+		if len(args) == 1 {
+			if _, ok := i3GapTypes[args[0]]; !ok {
+				log.Fatalf("unknown gap \"%s\", expected inner, outer, horizontal, vertical, top, right, bottom or left", args[0])
+			}
+			sides = i3GapTypes[args[0]]
+		}
+		ws, err := gapsWorkspace()
+		if err != nil {
+			log.Fatal(err)
+		}
+		s, err := readI3Gaps()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := resetGapOverrides(s, ws, sides); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	i3GapsCmd.AddCommand(i3GapsResetCmd)
+	i3GapsResetCmd.Flags().StringVarP(&_gapsWorkspace, "workspace", "w", "current", "Reset the gaps of the current workspace or of all workspaces.")
+}
+
+// resetGapOverrides forgets the gaps of a workspace, or of all workspaces if ws
+// is "", that were changed with 'dot i3 gaps', and sets them back.
+func resetGapOverrides(s i3GapsState, ws string, sides []string) error {
+	reset := map[string]int{}
+	for _, side := range sides {
+		if ws != "" {
+			if _, ok := s.Workspaces[ws][side]; ok {
+				delete(s.Workspaces[ws], side)
+				reset[side] = s.gap(ws, side)
+			}
+			continue
+		}
+		changed := false
+		if _, ok := s.All[side]; ok {
+			delete(s.All, side)
+			changed = true
+		}
+		for name := range s.Workspaces {
+			if _, ok := s.Workspaces[name][side]; ok {
+				delete(s.Workspaces[name], side)
+				changed = true
+			}
+		}
+		if changed {
+			reset[side] = s.baseGap(side)
+		}
+	}
+	if len(s.Workspaces[ws]) == 0 {
+		delete(s.Workspaces, ws)
+	}
+	for name := range s.Workspaces {
+		if len(s.Workspaces[name]) == 0 {
+			delete(s.Workspaces, name)
+		}
+	}
+	if len(reset) == 0 {
+		log.Infof("No gaps were changed with 'dot i3 gaps'")
+		return nil
+	}
+	for _, side := range i3GapSides {
+		size, ok := reset[side]
+		if !ok {
+			continue
+		}
+		if ws == "" {
+			if err := s.setAllGaps(side, size); err != nil {
+				return err
+			}
+			log.Infof("Reset the %s gap of all workspaces to %d", side, size)
+		} else {
+			log.Infof("Reset the %s gap of workspace \"%s\" to %d", side, ws, size)
+		}
+	}
+	if ws != "" {
+		if err := s.setWorkspaceGaps(map[string]map[string]int{ws: reset}); err != nil {
+			return err
+		}
+	}
+	return s.write()
+}
@@ -0,0 +1,182 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.i3wm.org/i3"
+)
+
+var _gapsWorkspace string
+
+// i3GapStep is how much inc and dec change a gap by default.
+const i3GapStep = 5
+
+var i3GapsSetCmd = &cobra.Command{
+	Use:   "set [gap] <size>",
+	Short: "Set a gap of the focused workspace or all workspaces.",
+	Long: `Sets a gap: inner, outer, horizontal, vertical, top, right, bottom or left (the
+default is inner). Room for bars is added to the size, so a top gap of 0 still
+leaves the bar uncovered.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		gap, size, ok, err := parseGapArgs(args)
+		if err == nil && !ok {
+			err = fmt.Errorf("set needs a size")
+		}
+		if err == nil {
+			err = changeI3Gaps(gap, func(current int) int { return size })
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var i3GapsIncCmd = &cobra.Command{
+	Use:   "inc [gap] [size]",
+	Short: fmt.Sprintf("Make a gap bigger, by %d pixels unless a size is given.", i3GapStep),
+	Args:  cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		gap, step, ok, err := parseGapArgs(args)
+		if !ok {
+			step = i3GapStep
+		}
+		if err == nil {
+			err = changeI3Gaps(gap, func(current int) int { return current + step })
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+var i3GapsDecCmd = &cobra.Command{
+	Use:   "dec [gap] [size]",
+	Short: fmt.Sprintf("Make a gap smaller, by %d pixels unless a size is given.", i3GapStep),
+	Args:  cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		gap, step, ok, err := parseGapArgs(args)
+		if !ok {
+			step = i3GapStep
+		}
+		if err == nil {
+			err = changeI3Gaps(gap, func(current int) int { return current - step })
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{i3GapsSetCmd, i3GapsIncCmd, i3GapsDecCmd} {
+		i3GapsCmd.AddCommand(c)
+		c.Flags().StringVarP(&_gapsWorkspace, "workspace", "w", "current", "Change the gap of the current workspace or of all workspaces.")
+	}
+}
+
+// parseGapArgs reads the [gap] [size] arguments of the gaps commands.
+func parseGapArgs(args []string) (string, int, bool, error) {
+	gap := "inner"
+	if len(args) > 0 {
+		if _, ok := i3GapTypes[args[0]]; ok {
+			gap, args = args[0], args[1:]
+		}
+	}
+	if len(args) == 0 {
+		return gap, 0, false, nil
+	}
+	if len(args) > 1 {
+		return gap, 0, false, fmt.Errorf("unknown gap \"%s\", expected inner, outer, horizontal, vertical, top, right, bottom or left", args[0])
+	}
+	size, err := strconv.Atoi(strings.TrimSuffix(args[0], "px"))
+	if err != nil || size < 0 {
+		return gap, 0, false, fmt.Errorf("invalid gap size \"%s\"", args[0])
+	}
+	return gap, size, true, nil
+}
+
+// gapsWorkspace returns the workspace the gaps commands change, "" for all.
+func gapsWorkspace() (string, error) {
+	switch _gapsWorkspace {
+	case "all":
+		return "", nil
+	case "current":
+		workspaces, err := i3.GetWorkspaces()
+		if err != nil {
+			return "", err
+		}
+		for _, ws := range workspaces {
+			if ws.Focused {
+				return ws.Name, nil
+			}
+		}
+		return "", fmt.Errorf("no workspace is focused")
+	}
+	return "", fmt.Errorf("invalid workspace \"%s\", expected current or all", _gapsWorkspace)
+}
+
+// changeI3Gaps sets every side of a gap to what change makes of its current
+// size, and saves the new sizes in dot's state so they are kept when a theme
+// is loaded.
+func changeI3Gaps(gap string, change func(current int) int) error {
+	ws, err := gapsWorkspace()
+	if err != nil {
+		return err
+	}
+	s, err := readI3Gaps()
+	if err != nil {
+		return err
+	}
+	sizes := map[string]int{}
+	for _, side := range i3GapTypes[gap] {
+		size := change(s.gap(ws, side))
+		if size < 0 {
+			size = 0
+		}
+		sizes[side] = size
+	}
+	return setGapOverrides(s, ws, sizes)
+}
+
+// setGapOverrides sets gaps of a workspace, or of all workspaces if ws is "",
+// and saves them. Gaps of all workspaces replace the ones of single workspaces,
+// like in i3.
+func setGapOverrides(s i3GapsState, ws string, sizes map[string]int) error {
+	for _, side := range i3GapSides {
+		size, ok := sizes[side]
+		if !ok {
+			continue
+		}
+		if ws == "" {
+			s.All[side] = size
+			for name := range s.Workspaces {
+				delete(s.Workspaces[name], side)
+				if len(s.Workspaces[name]) == 0 {
+					delete(s.Workspaces, name)
+				}
+			}
+			if err := s.setAllGaps(side, size); err != nil {
+				return err
+			}
+			log.Infof("Set the %s gap of all workspaces to %d", side, size)
+			continue
+		}
+		if s.Workspaces[ws] == nil {
+			s.Workspaces[ws] = map[string]int{}
+		}
+		s.Workspaces[ws][side] = size
+		log.Infof("Set the %s gap of workspace \"%s\" to %d", side, ws, size)
+	}
+	if ws != "" {
+		if err := s.setWorkspaceGaps(map[string]map[string]int{ws: sizes}); err != nil {
+			return err
+		}
+	}
+	return s.write()
+}
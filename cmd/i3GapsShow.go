@@ -0,0 +1,90 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var i3GapsShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the default gaps and the gaps dot set.",
+	Long: `Shows the default gaps and where they come from, the gaps dot set the last time
+it loaded a theme, and the gaps changed with 'dot i3 gaps'. i3 isn't asked for
+its gaps, gaps changed with i3's own commands aren't shown.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Printf("i3 config: %s\n\n", i3ConfigPath())
+		defaults := defaultI3Gaps()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "GAP\tDEFAULT\tSET IN")
+		for _, side := range i3GapSides {
+			g, ok := defaults[side]
+			if !ok {
+				g = i3Gap{"0", "i3"}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", side, g.Size, g.Source)
+		}
+		w.Flush()
+
+		s, err := readI3GapsState()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println()
+		if len(s.Gaps) == 0 {
+			fmt.Println("dot hasn't set any gaps yet.")
+			return
+		}
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "GAP\tCURRENT\tSET IN")
+		for _, side := range i3GapSides {
+			if g, ok := s.Gaps[side]; ok {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", side, g.Size, g.Source)
+			}
+			var outputs []string
+			for output, sizes := range s.Outputs {
+				if _, ok := sizes[side]; ok {
+					outputs = append(outputs, output)
+				}
+			}
+			sort.Strings(outputs)
+			for _, output := range outputs {
+				fmt.Fprintf(w, "%s on %s\t%d\troom for the bars\n", side, output, s.Outputs[output][side])
+			}
+		}
+		w.Flush()
+
+		if len(s.All) == 0 && len(s.Workspaces) == 0 {
+			return
+		}
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "GAP\tCHANGED TO\tWORKSPACE")
+		var workspaces []string
+		for ws := range s.Workspaces {
+			workspaces = append(workspaces, ws)
+		}
+		sort.Strings(workspaces)
+		for _, side := range i3GapSides {
+			if size, ok := s.All[side]; ok {
+				fmt.Fprintf(w, "%s\t%d\tall\n", side, size)
+			}
+			for _, ws := range workspaces {
+				if size, ok := s.Workspaces[ws][side]; ok {
+					fmt.Fprintf(w, "%s\t%d\t%s\n", side, size, ws)
+				}
+			}
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	i3GapsCmd.AddCommand(i3GapsShowCmd)
+}
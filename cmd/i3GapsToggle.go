@@ -0,0 +1,56 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var i3GapsToggleCmd = &cobra.Command{
+	Use:   "toggle [gap] [size]",
+	Short: "Turn a gap off, or back on.",
+	Long: `Sets a gap to 0 if it is on. A gap that is off is set to the size if one is
+given, or else back to what it was before 'dot i3 gaps' changed it.`,
+	Args: cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		gap, size, ok, err := parseGapArgs(args)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ws, err := gapsWorkspace()
+		if err != nil {
+			log.Fatal(err)
+		}
+		s, err := readI3Gaps()
+		if err != nil {
+			log.Fatal(err)
+		}
+		on := false
+		for _, side := range i3GapTypes[gap] {
+			on = on || s.gap(ws, side) > 0
+		}
+		sizes := map[string]int{}
+		for _, side := range i3GapTypes[gap] {
+			sizes[side] = size
+		}
+		switch {
+		case on:
+			for side := range sizes {
+				sizes[side] = 0
+			}
+			err = setGapOverrides(s, ws, sizes)
+		case ok:
+			err = setGapOverrides(s, ws, sizes)
+		default:
+			err = resetGapOverrides(s, ws, i3GapTypes[gap])
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	i3GapsCmd.AddCommand(i3GapsToggleCmd)
+	i3GapsToggleCmd.Flags().StringVarP(&_gapsWorkspace, "workspace", "w", "current", "Toggle the gap of the current workspace or of all workspaces.")
+}
@@ -0,0 +1,263 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.i3wm.org/i3"
+)
+
+var (
+	_i3Force  bool
+	_i3DryRun bool
+)
+
+// i3ConfigHeader starts every i3 config dot writes. A config without it was
+// written by hand, and is only replaced with --force.
+const i3ConfigHeader = "# Written by 'dot i3 load'."
+
+// i3ConfigBackups is how many backups of the i3 config are kept.
+const i3ConfigBackups = 10
+
+var i3LoadCmd = &cobra.Command{
+	Use:   "load",
+	Short: "Build the i3 config from fragments and reload i3.",
+	Long: `Joins the fragments of the i3 config into i3_wm.settings_file, in this order:
+- conf.d/*.conf next to the i3 config, e.g. ~/.config/i3/conf.d/10-keys.conf
+- conf.d/hosts/<hostname>/*.conf, for this computer only
+- i3.conf in the directory of the current theme and the themes it extends
+
+Fragments are joined in order of their names. The result is checked with 'i3 -C'
+before it replaces the i3 config, the previous config is backed up to
+~/.local/state/dot/i3-backups. i3 is reloaded if the config changed.
+
+Fragments are Go templates, e.g. 'workspace 1 output {{ .Displays.Primary }}':
+  .Hostname
+  .Config                dot's config, e.g. .Config.Displays.Current
+  .Displays.Primary      also .Left and .Right, like polybar's MONITOR_* variables
+  .Displays.Secondary    all but the primary output, a list
+  .Displays.Names        all active outputs, a list
+  .Displays.Aliases      the aliases in displays.aliases of active outputs
+  .Displays.Role "x"     the outputs of a role: primary, secondary, all or an alias
+  .Displays.Output "x"   the first output of a role, "" if there is none
+  .Theme                 the current theme, e.g. .Theme.Gaps.Inner
+  .Palette               its palette, e.g. .Palette.Accent
+  join                   e.g. {{ join .Displays.Names " " }}
+
+dot builds the config again after 'dot displays' and 'dot polybar' changed the
+layout or the theme, once it has written the i3 config.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := loadI3Config(_i3Force, _i3DryRun); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	i3Cmd.AddCommand(i3LoadCmd)
+	i3LoadCmd.Flags().BoolVarP(&_i3Force, "force", "f", false, "Replace an i3 config that wasn't written by dot. It is backed up first.")
+	i3LoadCmd.Flags().BoolVar(&_i3DryRun, "dry-run", false, "Print and check the i3 config without writing it.")
+}
+
+// i3SettingsFile returns the i3 config dot writes.
+func i3SettingsFile() (string, error) {
+	path := Config.I3wm.SettingsFile
+	if path == "" {
+		return "", fmt.Errorf("please set i3_wm.settings_file in current_settings.yml, it is where 'dot i3 load' writes the i3 config")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(Home, path)
+	}
+	return path, nil
+}
+
+// i3Fragments returns the fragments of the i3 config, in the order they are
+// joined.
+func i3Fragments(configFile string) ([]string, error) {
+	confDir := filepath.Join(filepath.Dir(configFile), "conf.d")
+	fragments, err := filepath.Glob(filepath.Join(confDir, "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(fragments)
+	if host, err := os.Hostname(); err == nil {
+		hostFragments, err := filepath.Glob(filepath.Join(confDir, "hosts", host, "*.conf"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(hostFragments)
+		fragments = append(fragments, hostFragments...)
+	}
+	if Config.Polybar.Theme != "" && Config.Polybar.ThemesDirectory != "" {
+		// the themes a theme extends come first, so the theme's lines win
+		chain := themeChain(Config.Polybar.Theme)
+		for i := len(chain) - 1; i >= 0; i-- {
+			path := filepath.Join(FullThemesPath, chain[i], "i3.conf")
+			if _, err := os.Stat(path); err == nil {
+				fragments = append(fragments, path)
+			}
+		}
+	}
+	return fragments, nil
+}
+
+// assembleI3Config renders the fragments of the i3 config and joins them.
+func assembleI3Config(configFile string) ([]byte, error) {
+	if Config.Polybar.ThemesDirectory != "" {
+		FullThemesPath = Home + "/" + Config.Polybar.ThemesDirectory
+	}
+	fragments, err := i3Fragments(configFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(fragments) == 0 {
+		return nil, fmt.Errorf("no i3 config fragments found in %s", filepath.Join(filepath.Dir(configFile), "conf.d"))
+	}
+	tmpl, err := i3Template()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, i3ConfigHeader)
+	fmt.Fprintln(&buf, "# Changes are overwritten, edit the fragments instead:")
+	for _, f := range fragments {
+		fmt.Fprintf(&buf, "#   %s\n", f)
+	}
+	for _, f := range fragments {
+		fragment, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		data, err := renderI3Fragment(f, fragment, tmpl)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "\n# --- %s\n", f)
+		buf.Write(data)
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// checkI3Config runs 'i3 -C' on a config.
+func checkI3Config(path string) error {
+	if _, err := exec.LookPath("i3"); err != nil {
+		return fmt.Errorf("i3 was not found, can't check the i3 config")
+	}
+	out, err := exec.Command("i3", "-C", "-c", path).CombinedOutput()
+	if err != nil || bytes.Contains(out, []byte("ERROR")) {
+		return fmt.Errorf("the i3 config has errors:\n%s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// loadI3Config builds the i3 config, checks it and, if it changed, replaces
+// the i3 config with it and reloads i3. force replaces an i3 config dot didn't
+// write, dryRun prints the config instead.
+func loadI3Config(force, dryRun bool) error {
+	configFile, err := i3SettingsFile()
+	if err != nil {
+		return err
+	}
+	data, err := assembleI3Config(configFile)
+	if err != nil {
+		return err
+	}
+	// the new config is checked next to the old one, so includes with
+	// relative paths are found
+	tmp := configFile + ".dot-new"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	if err := checkI3Config(tmp); err != nil {
+		return err
+	}
+	if dryRun {
+		fmt.Print(string(data))
+		log.Infof("The i3 config is valid, not writing it to %s", configFile)
+		return nil
+	}
+
+	old, err := ioutil.ReadFile(configFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if bytes.Equal(old, data) {
+		log.Infof("The i3 config %s is up to date", configFile)
+		return nil
+	}
+	if old != nil {
+		if !bytes.HasPrefix(old, []byte(i3ConfigHeader)) && !force {
+			return fmt.Errorf("%s wasn't written by dot, use --force to replace it. It will be backed up", configFile)
+		}
+		backup, err := backupI3Config(configFile, old)
+		if err != nil {
+			return fmt.Errorf("failed to back up the i3 config: %s", err)
+		}
+		log.Infof("Backed up the i3 config to %s", backup)
+	}
+	if err := os.Rename(tmp, configFile); err != nil {
+		return err
+	}
+	log.Infof("Wrote the i3 config to %s", configFile)
+
+	if _, err := i3.RunCommand("reload"); err != nil {
+		return fmt.Errorf("failed to reload i3: %s", err)
+	}
+	// reloading resets the gaps to the ones in the config
+	return restoreI3Gaps()
+}
+
+// backupI3Config saves a copy of the i3 config to dot's state, and removes
+// the oldest copies.
+func backupI3Config(configFile string, data []byte) (string, error) {
+	dir := filepath.Join(stateDir(), "i3-backups")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	backup := filepath.Join(dir, filepath.Base(configFile)+"."+time.Now().Format("20060102-150405"))
+	if err := ioutil.WriteFile(backup, data, 0644); err != nil {
+		return "", err
+	}
+	backups, err := filepath.Glob(filepath.Join(dir, filepath.Base(configFile)+".*"))
+	if err != nil {
+		return backup, err
+	}
+	sort.Strings(backups)
+	for len(backups) > i3ConfigBackups {
+		os.Remove(backups[0])
+		backups = backups[1:]
+	}
+	return backup, nil
+}
+
+// rebuildI3Config builds the i3 config again after the layout or the theme
+// changed, so fragments that use them are up to date. An i3 config dot didn't
+// write is left alone.
+func rebuildI3Config() {
+	configFile, err := i3SettingsFile()
+	if err != nil {
+		return
+	}
+	old, err := ioutil.ReadFile(configFile)
+	if err != nil || !bytes.HasPrefix(old, []byte(i3ConfigHeader)) {
+		return
+	}
+	if err := loadI3Config(false, false); err != nil {
+		log.Warnf("Failed to build the i3 config: %s", err)
+	}
+}
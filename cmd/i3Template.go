@@ -0,0 +1,129 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// i3TemplateData is what fragments of the i3 config can use, e.g.
+// workspace 1 output {{ .Displays.Primary }}
+type i3TemplateData struct {
+	Hostname string
+	// Config is dot's config, current_settings.yml.
+	Config config
+	// Displays are the connected displays.
+	Displays i3TemplateDisplays
+	// Theme is the current theme, with the themes it extends merged in. It is
+	// empty if no theme is loaded.
+	Theme Theme
+	// Palette is the palette of the current theme, empty if it has none.
+	Palette Palette
+}
+
+// i3TemplateDisplays are the connected displays. X is only asked for them when
+// a fragment uses them.
+type i3TemplateDisplays struct {
+	ds *displays
+}
+
+// Primary returns the primary output, e.g. DP-4.
+func (d i3TemplateDisplays) Primary() string {
+	return d.Output(rolePrimary)
+}
+
+// Left and Right return the outputs polybar knows as MONITOR_LEFT and
+// MONITOR_RIGHT.
+func (d i3TemplateDisplays) Left() string {
+	return d.ds.getLeft().name
+}
+
+func (d i3TemplateDisplays) Right() string {
+	return d.ds.getRight().name
+}
+
+// Secondary returns the outputs other than the primary one.
+func (d i3TemplateDisplays) Secondary() []string {
+	return d.Role(roleSecondary)
+}
+
+// Names returns all active outputs, left to right.
+func (d i3TemplateDisplays) Names() []string {
+	return d.Role(roleAll)
+}
+
+// Aliases returns the aliases of displays.aliases that name an active output.
+func (d i3TemplateDisplays) Aliases() map[string]string {
+	aliases := map[string]string{}
+	for alias := range Config.Displays.Aliases {
+		if output := d.Output(alias); output != "" {
+			aliases[alias] = output
+		}
+	}
+	return aliases
+}
+
+// Role returns the outputs of a role, like the roles of themes: primary,
+// secondary, all, a display alias or an output, e.g.
+// {{ range .Displays.Role "secondary" }}...{{ end }}
+func (d i3TemplateDisplays) Role(role string) []string {
+	var names []string
+	for _, m := range monitorsForRole(role, d.ds) {
+		names = append(names, m.name)
+	}
+	return names
+}
+
+// Output returns the first output of a role, or "" if it has none, e.g.
+// workspace 9 output {{ .Displays.Output "laptop" }}
+func (d i3TemplateDisplays) Output(role string) string {
+	if names := d.Role(role); len(names) > 0 {
+		return names[0]
+	}
+	return ""
+}
+
+var i3TemplateFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// i3Template returns the data for the fragments of the i3 config.
+func i3Template() (i3TemplateData, error) {
+	data := i3TemplateData{
+		Config:   Config,
+		Displays: i3TemplateDisplays{&displays{}},
+	}
+	data.Hostname, _ = os.Hostname()
+	if Config.Polybar.Theme == "" {
+		return data, nil
+	}
+	theme, err := loadTheme(Config.Polybar.Theme)
+	if err != nil {
+		return data, err
+	}
+	data.Theme = theme
+	if hasPalette(theme) {
+		if data.Palette, err = resolvePalette(theme); err != nil {
+			return data, err
+		}
+	}
+	return data, nil
+}
+
+// renderI3Fragment renders a fragment of the i3 config as a Go template.
+// Missing map keys are errors, so a typo in an alias isn't written as
+// "<no value>".
+func renderI3Fragment(path string, fragment []byte, data i3TemplateData) ([]byte, error) {
+	t, err := template.New(path).Option("missingkey=error").Funcs(i3TemplateFuncs).Parse(string(fragment))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
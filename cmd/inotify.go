@@ -0,0 +1,232 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const watchMask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM |
+	syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_ATTRIB
+
+// fileWatcher reports changes to files in a set of directories, using inotify.
+type fileWatcher struct {
+	// Events receives the path of every file that changed. It is closed once
+	// the watcher is closed.
+	Events chan string
+	fd     int
+	// mu guards dirs, paths can be added while events are read.
+	mu   sync.Mutex
+	dirs map[int]string
+	// read waits for fd and wake with epfd. Close stops read by writing to
+	// wake, closing fd doesn't wake up a blocked read(2).
+	epfd      int
+	wake      [2]int
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newFileWatcher watches dirs and their subdirectories. Directories that
+// don't exist are skipped.
+func newFileWatcher(dirs ...string) (*fileWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	w := &fileWatcher{Events: make(chan string), fd: fd, dirs: map[int]string{}, epfd: -1, wake: [2]int{-1, -1}, done: make(chan struct{})}
+	if err := w.initWake(); err != nil {
+		w.closeFds()
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if err := w.addTree(dir); err != nil {
+			w.closeFds()
+			return nil, err
+		}
+	}
+	go w.read()
+	return w, nil
+}
+
+// initWake sets up epoll for the inotify fd and the wake pipe.
+func (w *fileWatcher) initWake() error {
+	if err := syscall.Pipe2(w.wake[:], syscall.O_CLOEXEC|syscall.O_NONBLOCK); err != nil {
+		return os.NewSyscallError("pipe2", err)
+	}
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return os.NewSyscallError("epoll_create1", err)
+	}
+	w.epfd = epfd
+	for _, fd := range []int{w.fd, w.wake[0]} {
+		event := syscall.EpollEvent{Events: syscall.EPOLLIN, Fd: int32(fd)}
+		if err := syscall.EpollCtl(w.epfd, syscall.EPOLL_CTL_ADD, fd, &event); err != nil {
+			return os.NewSyscallError("epoll_ctl", err)
+		}
+	}
+	return nil
+}
+
+func (w *fileWatcher) closeFds() {
+	for _, fd := range []int{w.fd, w.epfd, w.wake[0], w.wake[1]} {
+		if fd >= 0 {
+			syscall.Close(fd)
+		}
+	}
+}
+
+// addTree watches dir and every directory below it.
+func (w *fileWatcher) addTree(dir string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return err
+		}
+		return w.add(path)
+	})
+}
+
+// add watches a single path. Files can be watched too.
+func (w *fileWatcher) add(path string) error {
+	wd, err := syscall.InotifyAddWatch(w.fd, path, watchMask)
+	if err != nil {
+		return os.NewSyscallError("inotify_add_watch "+path, err)
+	}
+	w.mu.Lock()
+	w.dirs[wd] = path
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *fileWatcher) read() {
+	defer close(w.Events)
+	defer w.closeFds()
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	ready := make([]syscall.EpollEvent, 2)
+	for {
+		n, err := syscall.EpollWait(w.epfd, ready, -1)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		for _, e := range ready[:n] {
+			if int(e.Fd) == w.wake[0] {
+				return
+			}
+		}
+		n, err = syscall.Read(w.fd, buf)
+		if err == syscall.EINTR || err == syscall.EAGAIN {
+			continue
+		}
+		if err != nil || n <= 0 {
+			return
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			e := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+int(e.Len)]), "\x00")
+			offset = nameStart + int(e.Len)
+
+			w.mu.Lock()
+			path := w.dirs[int(e.Wd)]
+			w.mu.Unlock()
+			if name != "" {
+				path = filepath.Join(path, name)
+			}
+			// watch directories created after the watcher started
+			if e.Mask&syscall.IN_CREATE != 0 && e.Mask&syscall.IN_ISDIR != 0 {
+				w.addTree(path)
+			}
+			if ignoredFile(name) {
+				continue
+			}
+			select {
+			case w.Events <- path:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+// ignoredFile reports whether a file is one of the temporary files editors
+// write while saving.
+func ignoredFile(name string) bool {
+	return strings.HasSuffix(name, "~") ||
+		strings.HasSuffix(name, ".swp") ||
+		strings.HasSuffix(name, ".swx") ||
+		strings.HasPrefix(name, ".#") ||
+		name == "4913"
+}
+
+// Close stops watching. Events is closed once the watcher stopped.
+func (w *fileWatcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.done)
+		_, err = syscall.Write(w.wake[1], []byte{0})
+	})
+	return err
+}
+
+// debounce collects events until none arrived for wait, then sends them as one
+// batch. Editors often write a file several times when saving it. The batches
+// end when events is closed, even if nobody takes them anymore.
+func debounce(events <-chan string, wait time.Duration) <-chan []string {
+	out := make(chan []string)
+	go func() {
+		defer close(out)
+		var batch []string
+		seen := map[string]bool{}
+		add := func(e string) {
+			if !seen[e] {
+				seen[e] = true
+				batch = append(batch, e)
+			}
+		}
+		timer := time.NewTimer(wait)
+		timer.Stop()
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				add(e)
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(wait)
+			case <-timer.C:
+				// events that arrive while the batch waits to be taken join it
+				for sent := false; !sent; {
+					select {
+					case out <- batch:
+						sent = true
+					case e, ok := <-events:
+						if !ok {
+							return
+						}
+						add(e)
+					}
+				}
+				batch = nil
+				seen = map[string]bool{}
+			}
+		}
+	}()
+	return out
+}
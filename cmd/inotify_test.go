@@ -0,0 +1,75 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestFileWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dot-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w, err := newFileWatcher(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batches := debounce(w.Events, 10*time.Millisecond)
+	path := filepath.Join(dir, "config")
+	for i := 0; i < 3; i++ {
+		if err := ioutil.WriteFile(path, []byte("[bar/main]\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case batch := <-batches:
+		if len(batch) != 1 || batch[0] != path {
+			t.Errorf("got batch %q, want [%s]", batch, path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change was reported")
+	}
+	w.Close()
+	select {
+	case _, ok := <-batches:
+		if ok {
+			t.Error("got a batch after the watcher was closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the batches didn't end after the watcher was closed")
+	}
+}
+
+func TestFileWatcherCloseStopsGoroutines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dot-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		w, err := newFileWatcher(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		debounce(w.Events, time.Millisecond)
+		// a change nobody takes from the batches
+		ioutil.WriteFile(filepath.Join(dir, "config"), []byte{}, 0644)
+		time.Sleep(10 * time.Millisecond)
+		w.Close()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("%d goroutines are left after closing the watchers, %d before", n, before)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// logMaxSize is the size at which a log file is rotated.
+	logMaxSize = 1 << 20
+	// logKeep is how many rotated files are kept, e.g. bar.log.1 to bar.log.3.
+	logKeep = 3
+)
+
+// stateDir is where dot keeps data that should survive a reboot but isn't
+// configuration, like logs.
+func stateDir() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		dir = filepath.Join(Home, ".local", "state")
+	}
+	return filepath.Join(dir, "dot")
+}
+
+func polybarLogDir() string {
+	return filepath.Join(stateDir(), "polybar")
+}
+
+// polybarLogFile returns the log file of a bar instance, e.g. main@DP-4.log.
+func polybarLogFile(bar string) string {
+	return filepath.Join(polybarLogDir(), bar+".log")
+}
+
+// rotatingFile is a log file that is rotated when it grows past logMaxSize.
+type rotatingFile struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	size int64
+}
+
+func openRotatingFile(path string) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	r := &rotatingFile{path: path}
+	return r, r.open()
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.size > 0 && r.size+int64(len(p)) > logMaxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate moves bar.log to bar.log.1, bar.log.1 to bar.log.2 and so on, and
+// starts a new bar.log.
+func (r *rotatingFile) rotate() error {
+	r.f.Close()
+	for i := logKeep - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
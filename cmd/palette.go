@@ -0,0 +1,237 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// paletteDir is where dot renders the palette of the loaded theme.
+func paletteDir() string {
+	return filepath.Join(stateDir(), "palette")
+}
+
+// paletteFile is a file dot renders the palette into. Apply makes the program
+// that reads the file pick up the new colors, if it doesn't notice itself.
+type paletteFile struct {
+	Name     string
+	Template *template.Template
+	Apply    func(path string) error
+}
+
+var paletteFiles = []paletteFile{
+	{"colors.ini", template.Must(template.New("polybar").Parse(`; Palette {{ .Name }}, written by dot. Changes are overwritten.
+[colors]
+background = {{ .Background }}
+foreground = {{ .Foreground }}
+accent = {{ .Accent }}
+urgent = {{ .Urgent }}
+{{- range $i, $c := .Colors }}
+color{{ $i }} = {{ $c }}
+{{- end }}
+`)), nil},
+	{"palette.rasi", template.Must(template.New("rofi").Parse(`/* Palette {{ .Name }}, written by dot. Changes are overwritten. */
+* {
+    background: {{ .Background }};
+    foreground: {{ .Foreground }};
+    accent:     {{ .Accent }};
+    urgent:     {{ .Urgent }};
+{{- range $i, $c := .Colors }}
+    color{{ $i }}: {{ $c }};
+{{- end }}
+}
+`)), nil},
+	{"Xresources", template.Must(template.New("xresources").Parse(`! Palette {{ .Name }}, written by dot. Changes are overwritten.
+*.background:  {{ .Background }}
+*.foreground:  {{ .Foreground }}
+*.cursorColor: {{ .Foreground }}
+{{- range $i, $c := .Colors }}
+*.color{{ $i }}: {{ $c }}
+{{- end }}
+`)), mergeXresources},
+	{"alacritty.yml", template.Must(template.New("alacritty").Parse(`# Palette {{ .Name }}, written by dot. Changes are overwritten.
+colors:
+  primary:
+    background: '{{ .Background }}'
+    foreground: '{{ .Foreground }}'
+{{- with .Colors }}
+  normal:
+    black:   '{{ index . 0 }}'
+    red:     '{{ index . 1 }}'
+    green:   '{{ index . 2 }}'
+    yellow:  '{{ index . 3 }}'
+    blue:    '{{ index . 4 }}'
+    magenta: '{{ index . 5 }}'
+    cyan:    '{{ index . 6 }}'
+    white:   '{{ index . 7 }}'
+  bright:
+    black:   '{{ index . 8 }}'
+    red:     '{{ index . 9 }}'
+    green:   '{{ index . 10 }}'
+    yellow:  '{{ index . 11 }}'
+    blue:    '{{ index . 12 }}'
+    magenta: '{{ index . 13 }}'
+    cyan:    '{{ index . 14 }}'
+    white:   '{{ index . 15 }}'
+{{- end }}
+`)), nil},
+	{"kitty.conf", template.Must(template.New("kitty").Parse(`# Palette {{ .Name }}, written by dot. Changes are overwritten.
+background {{ .Background }}
+foreground {{ .Foreground }}
+cursor     {{ .Foreground }}
+selection_background {{ .Accent }}
+selection_foreground {{ .Background }}
+{{- range $i, $c := .Colors }}
+color{{ $i }} {{ $c }}
+{{- end }}
+`)), nil},
+}
+
+var paletteColorRe = regexp.MustCompile(`^#([0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+
+// mergePalette returns base with every color that is set in override replaced.
+func mergePalette(base, override Palette) Palette {
+	if override.Name != "" {
+		base.Name = override.Name
+	}
+	if override.Background != "" {
+		base.Background = override.Background
+	}
+	if override.Foreground != "" {
+		base.Foreground = override.Foreground
+	}
+	if override.Accent != "" {
+		base.Accent = override.Accent
+	}
+	if override.Urgent != "" {
+		base.Urgent = override.Urgent
+	}
+	if len(override.Colors) > 0 {
+		base.Colors = override.Colors
+	}
+	return base
+}
+
+// hasPalette reports whether the theme sets a palette.
+func hasPalette(t Theme) bool {
+	p := t.Palette
+	return p.Name != "" || p.Background != "" || p.Foreground != "" || p.Accent != "" || p.Urgent != "" || len(p.Colors) > 0
+}
+
+// resolvePalette returns the theme's palette with the palette it names from
+// palettes filled in, and checks its colors.
+func resolvePalette(t Theme) (Palette, error) {
+	p := t.Palette
+	if p.Name != "" {
+		found := false
+		for _, named := range Config.Palettes {
+			if named.Name == p.Name {
+				p = mergePalette(named, p)
+				found = true
+				break
+			}
+		}
+		// a palette defined in the theme only needs a name for the files
+		if !found && (p.Background == "" || p.Foreground == "") {
+			return p, fmt.Errorf("palette \"%s\" of theme \"%s\" is not defined in palettes", p.Name, t.Name)
+		}
+	} else {
+		p.Name = t.Name
+	}
+	if p.Accent == "" {
+		p.Accent = p.Foreground
+	}
+	if p.Urgent == "" {
+		p.Urgent = p.Accent
+	}
+	if p.Background == "" || p.Foreground == "" {
+		return p, fmt.Errorf("palette \"%s\" needs a background and a foreground", p.Name)
+	}
+	if len(p.Colors) != 0 && len(p.Colors) != 16 {
+		return p, fmt.Errorf("palette \"%s\" has %d colors, expected 16", p.Name, len(p.Colors))
+	}
+	colors := append([]string{p.Background, p.Foreground, p.Accent, p.Urgent}, p.Colors...)
+	for _, c := range colors {
+		if !paletteColorRe.MatchString(c) {
+			return p, fmt.Errorf("palette \"%s\" has invalid color \"%s\", expected #rrggbb", p.Name, c)
+		}
+	}
+	return p, nil
+}
+
+// renderPalette writes the theme's palette to paletteDir and applies the files
+// that changed. For themes without a palette, the rendered palette is removed.
+func renderPalette(t Theme) error {
+	if !hasPalette(t) {
+		return removePalette()
+	}
+	p, err := resolvePalette(t)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(paletteDir(), 0755); err != nil {
+		return err
+	}
+	var changed []string
+	for _, f := range paletteFiles {
+		var buf bytes.Buffer
+		if err := f.Template.Execute(&buf, p); err != nil {
+			return err
+		}
+		path := filepath.Join(paletteDir(), f.Name)
+		if old, err := ioutil.ReadFile(path); err == nil && bytes.Equal(old, buf.Bytes()) {
+			continue
+		}
+		if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		changed = append(changed, f.Name)
+		if f.Apply == nil {
+			continue
+		}
+		if err := f.Apply(path); err != nil {
+			log.Warnf("Failed to apply %s: %s", path, err)
+		}
+	}
+	if len(changed) > 0 {
+		log.Infof("Rendered palette \"%s\" to %s: %s", p.Name, paletteDir(), strings.Join(changed, ", "))
+	}
+	return nil
+}
+
+// removePalette removes the palette files of the previous theme, so its
+// colors don't stay around after a theme without a palette is loaded.
+func removePalette() error {
+	var removed []string
+	for _, f := range paletteFiles {
+		err := os.Remove(filepath.Join(paletteDir(), f.Name))
+		if err == nil {
+			removed = append(removed, f.Name)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if len(removed) > 0 {
+		log.Infof("Removed the palette from %s, the theme has none: %s", paletteDir(), strings.Join(removed, ", "))
+	}
+	return nil
+}
+
+func mergeXresources(path string) error {
+	if _, err := exec.LookPath("xrdb"); err != nil {
+		return fmt.Errorf("xrdb was not found")
+	}
+	out, err := exec.Command("xrdb", "-merge", path).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("xrdb -merge failed: %s\n%s", err, out)
+	}
+	return nil
+}
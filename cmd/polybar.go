@@ -3,17 +3,14 @@
 package cmd
 
 import (
-	"bufio"
-	"bytes"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"os"
-	"os/exec"
-	"regexp"
-	"strings"
-	"sync"
+	"path/filepath"
+	"sort"
+	"strconv"
 
+	"github.com/patrick-motard/dot/lib"
 	"github.com/patrick-motard/rofigo"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -23,8 +20,11 @@ import (
 
 var (
 	_theme                 string
+	_palette               string
 	_list                  bool
 	_select                bool
+	_watch                 bool
+	_ifRunning             string
 	themeIsValid           bool
 	InstalledPolybarThemes []string
 	FullThemePath          string
@@ -45,13 +45,27 @@ var polybarCmd = &cobra.Command{
 			os.Exit(0)
 		}
 		if _select == true {
-			v := rofigo.New("Select Polybar theme", InstalledPolybarThemes...)
+			v := rofigo.New("Select Polybar theme", themeChoices()...)
 			v.Show()
 			log.Infof("You selected: %s", v.Selection)
 
 			_theme = v.Selection
 		}
 
+		// only one dot command loads bars at a time. The lock is released when
+		// dot exits, including on log.Fatal.
+		if err := checkIfRunning(_ifRunning); err != nil {
+			log.Fatal(err)
+		}
+		lock, err := lockPolybar(_ifRunning)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// another dot command may have loaded a theme while we waited
+		if err := viper.ReadInConfig(); err == nil {
+			Config.Polybar.Theme = viper.GetString("polybar.theme")
+		}
+
 		if _theme == "" {
 			_theme = Config.Polybar.Theme
 			log.Infof("No theme specified, reloading default: \"%s\"", _theme)
@@ -61,24 +75,73 @@ var polybarCmd = &cobra.Command{
 			log.Fatalln(fmt.Sprintf("Theme: \"%s\" was not found", _theme))
 		}
 
-		viper.Set("polybar.theme", _theme)
+		previous, previousPalette := Config.Polybar.Theme, Config.Polybar.Palette
 		Config.Polybar.Theme = _theme
-
-		FullThemePath = FullThemesPath + "/" + _theme + "/config"
-		// TODO: also check if theme is new and succeeded loading
-		if themeIsValid {
-			viper.WriteConfig()
+		// a theme that is picked starts out with its own palette
+		if cmd.Flags().Changed("theme") || cmd.Flags().Changed("palette") || _select {
+			Config.Polybar.Palette = _palette
 		}
 
-		main()
+		FullThemePath = themeConfigPath(_theme)
+		// check the theme before the running bars are stopped
+		theme, err := loadTheme(_theme)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// the bars may include the palette, which has to exist to check them
+		if _, err := os.Stat(filepath.Join(paletteDir(), "colors.ini")); os.IsNotExist(err) {
+			if err := renderPalette(theme); err != nil {
+				log.Errorf("Failed to render the palette: %s", err)
+			}
+		}
+		if problems := checkTheme(theme, FullThemePath); len(problems) > 0 {
+			for _, p := range problems {
+				fmt.Println(p)
+			}
+			log.Fatalf("Theme \"%s\" has %d problem(s), not loading it", _theme, len(problems))
+		}
+		// the theme is only saved once its bars are up, a theme that fails to
+		// load is replaced by the previous theme again
+		err = loadPolybar()
+		if err == errLoadCancelled {
+			log.Infof("Not loading theme \"%s\", another dot command is loading bars", _theme)
+			lock.Unlock()
+			return
+		}
+		if err != nil {
+			failed := _theme
+			log.Errorf("Loading theme \"%s\" failed: %s", failed, err)
+			Config.Polybar.Palette = previousPalette
+			if err := rollbackTheme(previous); err != nil {
+				log.Fatalln(err)
+			}
+			log.Fatalf("Theme \"%s\" was not loaded, \"%s\" is running again", failed, previous)
+		}
+		viper.Set("polybar.theme", _theme)
+		viper.Set("polybar.palette", Config.Polybar.Palette)
+		if err := viper.WriteConfig(); err != nil {
+			log.Errorln(err)
+		}
+		notifyPolybar("theme")
+		// the theme may have wallpapers of its own
+		reapplyWallpapers()
+		rebuildI3Config()
+		lock.Unlock()
+		if _watch {
+			if err := watchTheme(); err != nil {
+				log.Fatalln(err)
+			}
+		}
 	},
 }
 
 func init() {
 	polybarCmd.Flags().StringVarP(&_theme, "theme", "t", "", "Load a Polybar theme by name. The theme specified will be saved to dot's current_settings.")
+	polybarCmd.Flags().StringVarP(&_palette, "palette", "p", "", "Use a palette from palettes instead of the theme's own. Picking a theme without it goes back to the theme's palette.")
 	polybarCmd.Flags().BoolVarP(&_list, "list", "l", false, "Lists all themes found on the system.")
 	polybarCmd.Flags().BoolVarP(&_select, "select", "s", false, "Select a theme interactively.")
-	viper.BindPFlag("polybar.theme", polybarCmd.Flags().Lookup("theme"))
+	polybarCmd.Flags().BoolVarP(&_watch, "watch", "w", false, "Keep running and reload the bars when the theme's files change.")
+	polybarCmd.Flags().StringVar(&_ifRunning, "if-running", ifRunningWait, "What to do if another dot command is loading bars: wait, cancel or fail.")
 	// TODO: This is putting list on viper, which is then written to file
 	// either figure out how to unmarshal Config and overwrite current settings with it,
 	// or figure out how to reference flags without viper. 'list=true' doesn't belong in current_settings
@@ -86,11 +149,31 @@ func init() {
 }
 
 func listThemes() {
-	for _, t := range InstalledPolybarThemes {
+	for _, t := range themeChoices() {
 		fmt.Println(t)
 	}
 }
 
+// themeChoices returns the installed themes followed by their variants,
+// e.g. nord, nord:laptop.
+func themeChoices() []string {
+	var choices []string
+	for _, name := range InstalledPolybarThemes {
+		choices = append(choices, name)
+		t, err := resolveTheme(name, nil)
+		if err != nil {
+			continue
+		}
+		var variants []string
+		for v := range t.Variants {
+			variants = append(variants, name+":"+v)
+		}
+		sort.Strings(variants)
+		choices = append(choices, variants...)
+	}
+	return choices
+}
+
 func findThemes() {
 	if Config.Polybar.ThemesDirectory == "" {
 		log.Fatalln("Please set polybar.themes_directory in current_settings.yml")
@@ -100,7 +183,7 @@ func findThemes() {
 
 	// Look up installed themes.
 	// A theme is considered to be installed if there is a directory with the themes name,
-	// in the themes folder.
+	// in the themes folder, that has a bar config or a theme.yml.
 	f, err := ioutil.ReadDir(FullThemesPath)
 	if err != nil {
 		log.Errorln(err)
@@ -108,195 +191,212 @@ func findThemes() {
 	}
 
 	for _, x := range f {
-		if x.IsDir() && x.Name() != "global" {
+		if !x.IsDir() || x.Name() == "global" {
+			continue
+		}
+		if isThemeDir(filepath.Join(FullThemesPath, x.Name())) {
 			InstalledPolybarThemes = append(InstalledPolybarThemes, x.Name())
 		}
 	}
 }
 
+// validateTheme reports whether _theme is installed. Variants are validated
+// when the theme is loaded.
 func validateTheme() bool {
+	name, _ := splitThemeName(_theme)
 	for _, x := range InstalledPolybarThemes {
-		if _theme == x {
+		if name == x {
 			return true
 		}
 	}
 	return false
 }
 
-func main() {
-
+// loadPolybar stops the bars dot started before and starts the bars of the current theme.
+func loadPolybar() error {
 	ds := displays{}
+	polybarEnv := polybarMonitorEnv(&ds)
+	log.Infoln(fmt.Sprintf("polybar_theme=%s", FullThemePath))
 
-	// kill all polybar sessions polybar
-	cmd := exec.Command("sh", "-c", "killall -q polybar")
-	out, err := cmd.CombinedOutput()
+	// get the theme object for current theme from current_settings
+	theme, err := loadTheme(Config.Polybar.Theme)
 	if err != nil {
-		// TODO: handle case where polybar isn't running yet
-		// log.Fatalln("Failed to kill polybar")
-		log.Println("Failed to kill polybar")
+		return err
 	}
-	fmt.Println(string(out))
-
-	// create the env vars we'll hand to polybar
-	// polybar needs to know the theme, and what the left, right and main monitor are
-	var polybarEnvVars []string
-
-	s := fmt.Sprintf("MONITOR_MAIN=%s", ds.getPrimary().name)
-	polybarEnvVars = append(polybarEnvVars, s)
-
-	s = fmt.Sprintf("MONITOR_LEFT=%s", ds.getLeft().name)
-	polybarEnvVars = append(polybarEnvVars, s)
-
-	s = fmt.Sprintf("MONITOR_RIGHT=%s", ds.getRight().name)
-	polybarEnvVars = append(polybarEnvVars, s)
-
-	// add the theme to the environment
-	t := fmt.Sprintf("polybar_theme=%s", FullThemePath)
-	log.Infoln(t)
-	polybarEnvVars = append(polybarEnvVars, t)
-
-	// create a new array of env vars, appending the current environment
-	// with the env vars created above
-	newEnv := append(os.Environ(), polybarEnvVars...)
-
-	// get the theme object for current theme from current_settings
-	var theme Theme
-	// TODO: maybe switch Themes to a map so i don't have to loop
-	for _, t := range Config.Polybar.Themes {
-		if Config.Polybar.Theme == t.Name {
-			theme = t
-		}
+	b, err := themeBackend(theme)
+	if err != nil {
+		return err
+	}
+	// i3 is reloaded when its colors or settings change, which resets the
+	// gaps, so the palette and the i3 settings go before the gaps are set
+	if err := renderPalette(theme); err != nil {
+		log.Errorf("Failed to render the palette: %s", err)
 	}
-	// Exit if it fails to find the theme object
-	if theme.Name == "" {
-		log.Error("Theme not found, exiting.")
-		os.Exit(1)
+	if err := applyI3Appearance(theme); err != nil {
+		log.Errorf("Failed to apply the i3 settings: %s", err)
 	}
 
-	// load bars from theme's .rasi file if none were specified in current_settings.yml
+	// bars assigned to monitor roles get one instance per matching monitor
+	instances := roleBarInstances(theme, &ds)
+
+	// load bars from theme's config file if none were specified in current_settings.yml
 	var bars []string
-	if len(theme.Bars) == 0 {
+	if len(theme.Bars) == 0 && len(theme.Roles) == 0 {
 		log.Infoln("No bars specified in current-settings file. Auto-detecting bars...")
-		bars = getBars(theme, FullThemePath)
+		bars, err = getBars(b)
+		if err != nil {
+			return err
+		}
 	} else {
 		log.Infoln("Bars specified in current-settings file...")
 		bars = theme.Bars
 	}
-	adjustI3Gaps(theme.Gaps)
-	// start all the bars
-	var wg sync.WaitGroup
 	for _, bar := range bars {
-		log.Infoln(fmt.Sprintf("Loading bar '%s'", bar))
-		wg.Add(1)
-		go func(b string) {
-			defer wg.Done()
-			polybar(newEnv, b)
-		}(bar)
+		if !b.HasMonitor(FullThemePath, bar, polybarEnv) {
+			log.Infof("Skipping bar '%s', its monitor isn't connected", bar)
+			continue
+		}
+		instances = append(instances, barInstance{Bar: bar})
+	}
+	if len(instances) == 0 {
+		return fmt.Errorf("no bars to load for theme \"%s\"", theme.Name)
+	}
+
+	// a dot command that was cancelled stops here, while the running bars
+	// are still up
+	if heldLock.Cancelled() {
+		return errLoadCancelled
+	}
+	// stop the bars dot started previously, leaving other bars alone
+	if err := stopBars(); err != nil {
+		log.Errorln(err)
 	}
-	wg.Wait()
 
-	// g := getDefaultI3Gaps()
+	gaps, err := b.Gaps(FullThemePath, instances, polybarEnv)
+	if err != nil {
+		log.Errorf("Failed to work out the room the bars need: %s", err)
+	}
+	adjustI3Gaps(theme.Gaps, gaps)
+	for _, i := range instances {
+		log.Infoln(fmt.Sprintf("Loading bar '%s'", i))
+	}
+
+	return b.Launch(theme, FullThemePath, instances, polybarEnv)
+}
+
+// rollbackTheme stops the bars of a theme that failed to load and loads the
+// previous theme again, which also restores its gaps.
+func rollbackTheme(previous string) error {
+	if err := stopBars(); err != nil {
+		log.Errorln(err)
+	}
+	if previous == "" || previous == _theme {
+		return fmt.Errorf("there is no previous theme to go back to")
+	}
+	log.Infof("Going back to theme \"%s\"", previous)
+	_theme = previous
+	Config.Polybar.Theme = previous
+	FullThemePath = themeConfigPath(previous)
+	if err := loadPolybar(); err == errLoadCancelled {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("loading the previous theme \"%s\" failed too: %s", previous, err)
+	}
+	return nil
+}
+
+// polybarMonitorEnv creates the env vars we'll hand to polybar.
+// polybar needs to know the theme, and what the left, right and main monitor are.
+// Bars launched for a role also get the monitor they belong on as MONITOR.
+func polybarMonitorEnv(ds *displays) map[string]string {
+	return map[string]string{
+		"MONITOR_MAIN":  ds.getPrimary().name,
+		"MONITOR_LEFT":  ds.getLeft().name,
+		"MONITOR_RIGHT": ds.getRight().name,
+		"polybar_theme": FullThemePath,
+	}
 }
 
 // Polybar themes can specify the gaps between i3 and the bar(s). This is useful
 // when i3 doesn't respect the height of the bar, which happens when certain settings
-// are enabled in polybar.
-func adjustI3Gaps(g I3Gaps) {
-	sides := []string{"top", "bottom", "left", "right"}
-	sizes := []string{g.Top, g.Bottom, g.Left, g.Right}
-	d := Config.I3wm.DefaultGaps
-	defaults := []string{d.Top, d.Bottom, d.Left, d.Right}
+// are enabled in polybar. Themes can set the inner gap too, gaps they don't
+// specify go back to the default gaps. Top and bottom gaps are derived
+// from the bars, see barGaps, and added to the default gaps, see defaultI3Gaps.
+// What the gaps were set to is saved for 'dot i3 gaps show', and gaps changed
+// with 'dot i3 gaps' are set again afterwards.
+func adjustI3Gaps(g I3Gaps, bars map[string]edgeGaps) {
+	sides := []string{"inner", "top", "bottom", "left", "right"}
+	sizes := []string{g.Inner, g.Top, g.Bottom, g.Left, g.Right}
+	defaults := defaultI3Gaps()
+	// gaps changed with 'dot i3 gaps' are kept
+	state, err := readI3GapsState()
+	if err != nil {
+		log.Errorln(err)
+	}
+	state.Gaps, state.Outputs = map[string]i3Gap{}, map[string]map[string]int{}
 
 	for i, s := range sides {
 		var err error
-		if sizes[i] == "" {
-			log.Info(fmt.Sprintf("Setting i3wm \"%s\" gap to default: \"%s\"", s, defaults[i]))
-			_, err = i3.RunCommand(fmt.Sprintf("gaps %s all set %s", s, defaults[i]))
-		} else {
+		def, ok := defaults[s]
+		if !ok {
+			def = i3Gap{"0", "i3"}
+		}
+		needed := map[string]int{}
+		for output, b := range bars {
+			if s == "top" && b.Top > 0 {
+				needed[output] = b.Top
+			} else if s == "bottom" && b.Bottom > 0 {
+				needed[output] = b.Bottom
+			}
+		}
+		switch {
+		case sizes[i] != "":
 			log.Info(fmt.Sprintf("Setting i3wm \"%s\" gap to \"%s\", specified in theme: \"%s\"", s, sizes[i], _theme))
 			_, err = i3.RunCommand(fmt.Sprintf("gaps %s all set %s", s, sizes[i]))
+			state.Gaps[s] = i3Gap{sizes[i], "theme " + _theme}
+		case len(needed) > 0:
+			size, _ := strconv.Atoi(def.Size)
+			for output := range needed {
+				needed[output] += size
+				log.Info(fmt.Sprintf("Setting i3wm \"%s\" gap on \"%s\" to \"%d\" to make room for the bars", s, output, needed[output]))
+				if state.Outputs[output] == nil {
+					state.Outputs[output] = map[string]int{}
+				}
+				state.Outputs[output][s] = needed[output]
+			}
+			err = setI3Gaps(s, needed, size)
+			state.Gaps[s] = def
+		default:
+			log.Info(fmt.Sprintf("Setting i3wm \"%s\" gap to default: \"%s\" (%s)", s, def.Size, def.Source))
+			_, err = i3.RunCommand(fmt.Sprintf("gaps %s all set %s", s, def.Size))
+			state.Gaps[s] = def
 		}
 		if err != nil {
 			log.Errorln(err)
 		}
 	}
-}
-
-// func getDefaultI3Gaps() I3Gaps {
-// 	f, err := os.Open(Home + "/" + Config.I3SettingsFile)
-// 	if err != nil {
-// 		log.Fatal(err)
-// 	}
-// 	defer f.Close()
-// 	var g I3Gaps
-// 	scanner := bufio.NewScanner(f)
-// 	// re := regexp.MustCompile(`^gaps top`)
-// 	for scanner.Scan() {
-// 		// log.Info(scanner.Text())
-// 		if strings.Contains(scanner.Text(), "gaps top") {
-// 			log.Infoln(scanner.Text())
-// 			// m := re.FindSubmatch(scanner.Bytes())
-// 			// log.Infoln(string(m[0]))
-// 			// b = append(b, string(m[1]))
-// 		}
-// 	}
-// 	if err := scanner.Err(); err != nil {
-// 		log.Errorln(err)
-// 	}
-// 	return g
-// }
-
-func getBars(theme Theme, path string) []string {
-	f, err := os.Open(path)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer f.Close()
-	var b []string
-	scanner := bufio.NewScanner(f)
-	// example: [bar/SOME.BAR] -> SOME.BAR
-	re := regexp.MustCompile(`^\[bar\/(.*?)\]`)
-	for scanner.Scan() {
-		if strings.Contains(scanner.Text(), "[bar/") {
-			m := re.FindSubmatch(scanner.Bytes())
-			b = append(b, string(m[1]))
-		}
+	if err := state.write(); err != nil {
+		log.Errorln(err)
 	}
-	if len(b) == 0 {
-		// TODO use a public variable to reference the path to current_settings.yml
-		log.Fatalf("No bars found in:\n - %s\n - %s", FullThemePath, Home+"/code/dot/current_settings.yml")
+	if err := applyI3GapOverrides(state); err != nil {
+		log.Errorln(err)
 	}
-	return b
 }
 
-func polybar(env []string, bar string) {
-
-	s := fmt.Sprintf("polybar -r %s", bar)
-	cmd := exec.Command("bash", "-c", s)
-	cmd.Env = env
-
-	var stdoutBuf, stderrBuf bytes.Buffer
-	stdoutIn, _ := cmd.StdoutPipe()
-	stderrIn, _ := cmd.StderrPipe()
-
-	var errStdout, errStderr error
-	stdout := io.MultiWriter(os.Stdout, &stdoutBuf)
-	stderr := io.MultiWriter(os.Stderr, &stderrBuf)
-	err := cmd.Start()
-	if err != nil {
-		log.Errorf("cmd.Start() failed with '%s'\n", err)
-	}
-
-	_, errStdout = io.Copy(stdout, stdoutIn)
-	_, errStderr = io.Copy(stderr, stderrIn)
+// loadPolybarConfig parses a theme's polybar config. Includes that aren't found
+// next to the config are looked up in the themes directory and in the 'global'
+// theme, so themes can share files.
+func loadPolybarConfig(path string) (*lib.PolybarConfig, error) {
+	return lib.ParsePolybarConfig(path, FullThemesPath, filepath.Join(FullThemesPath, "global"))
+}
 
-	err = cmd.Wait()
+func getBars(backend barBackend) ([]string, error) {
+	b, err := backend.Bars(FullThemePath)
 	if err != nil {
-		log.Errorf("Starting bar %s failed with %s\n", bar, err)
+		return nil, err
 	}
-	if errStdout != nil || errStderr != nil {
-		log.Error("failed to capture stdout or stderr\n")
+	if len(b) == 0 {
+		return nil, fmt.Errorf("no bars found in:\n - %s\n - %s", FullThemePath, cfgFile)
 	}
-	outStr, errStr := string(stdoutBuf.Bytes()), string(stderrBuf.Bytes())
-	fmt.Printf("stdout:\n%s\nerr:\n%s\n", outStr, errStr)
+	return b, nil
 }
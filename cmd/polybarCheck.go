@@ -0,0 +1,474 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/patrick-motard/dot/lib"
+	"github.com/spf13/cobra"
+)
+
+var polybarCheckCmd = &cobra.Command{
+	Use:   "check [theme]",
+	Short: "Check a polybar theme for problems without loading it.",
+	Long: `Checks that:
+- every bar listed for the theme in polybar.themes (bars and roles) exists in the theme's config
+- every module in modules-left, modules-center and modules-right is defined
+- custom/script modules point at executables
+- the bars' fonts and the fonts listed in the theme's theme.yml are installed (using fc-match)
+- the theme's palette exists and has valid colors
+- the theme's i3 settings are valid and its i3 font is installed
+
+Checks the current theme if no theme is given. 'dot polybar' runs the same checks
+before it stops the running bars.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		FullThemesPath = Home + "/" + Config.Polybar.ThemesDirectory
+		findThemes()
+		_theme = Config.Polybar.Theme
+		if len(args) == 1 {
+			_theme = args[0]
+		}
+		if !validateTheme() {
+			log.Fatalf("Theme: \"%s\" was not found", _theme)
+		}
+		theme, err := loadTheme(_theme)
+		if err != nil {
+			log.Fatal(err)
+		}
+		problems := checkTheme(theme, themeConfigPath(_theme))
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		if len(problems) > 0 {
+			log.Fatalf("Theme \"%s\" has %d problem(s)", _theme, len(problems))
+		}
+		log.Infof("Theme \"%s\" looks good", _theme)
+	},
+}
+
+func init() {
+	polybarCmd.AddCommand(polybarCheckCmd)
+}
+
+// Kinds of theme problems.
+const (
+	problemConfig  = "config"
+	problemBar     = "bar"
+	problemModule  = "module"
+	problemScript  = "script"
+	problemFont    = "font"
+	problemPalette = "palette"
+	problemI3      = "i3"
+)
+
+// themeProblem is something in a theme that will stop it from loading properly.
+type themeProblem struct {
+	Kind    string
+	Pos     string // file:line
+	Message string
+}
+
+func (p themeProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Pos, p.Message)
+}
+
+// themeConfigPath returns the path to the bar config of an installed theme.
+// A theme without a config of its own uses the config of the theme it extends.
+func themeConfigPath(name string) string {
+	file := barBackends[defaultBarBackend].ConfigFile()
+	base, _ := splitThemeName(name)
+	if t, err := resolveTheme(base, nil); err == nil {
+		if b, err := themeBackend(t); err == nil {
+			file = b.ConfigFile()
+		}
+	}
+	chain := themeChain(name)
+	for _, n := range chain {
+		path := filepath.Join(FullThemesPath, n, file)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(FullThemesPath, chain[0], file)
+}
+
+// findTheme returns the theme's entry in polybar.themes. Only the name is set
+// if the theme isn't listed there. Use loadTheme to include the theme's theme.yml.
+func findTheme(name string) Theme {
+	name, _ = splitThemeName(name)
+	// TODO: maybe switch Themes to a map so i don't have to loop
+	for _, t := range Config.Polybar.Themes {
+		if t.Name == name {
+			return t
+		}
+	}
+	return Theme{Name: name}
+}
+
+// checkTheme parses the theme's config and returns every problem found in it.
+func checkTheme(theme Theme, path string) []themeProblem {
+	metadata := filepath.Join(filepath.Dir(path), themeMetadataFile)
+	b, err := themeBackend(theme)
+	if err != nil {
+		return []themeProblem{{Kind: problemConfig, Pos: themeKeyPos(theme, metadata, "backend"), Message: err.Error()}}
+	}
+	defined, err := b.Bars(path)
+	if err != nil {
+		return []themeProblem{{Kind: problemConfig, Pos: path, Message: err.Error()}}
+	}
+	isDefined := map[string]bool{}
+	for _, bar := range defined {
+		isDefined[bar] = true
+	}
+	var problems []themeProblem
+	bars := append([]string{}, theme.Bars...)
+	for _, roleBars := range theme.Roles {
+		bars = append(bars, roleBars...)
+	}
+	// bars come from the theme's theme.yml unless polybar.themes overrides
+	// them, then they are looked for in the theme's entry only
+	barsFile, first, last := metadata, 1, 0
+	if o := findTheme(theme.Name); len(o.Bars) > 0 || len(o.Roles) > 0 {
+		base, _ := splitThemeName(theme.Name)
+		barsFile = cfgFile
+		first, last = themeEntryLines(cfgFile, base)
+	}
+	for _, bar := range bars {
+		if !isDefined[bar] {
+			pos := barsFile
+			if first > 0 {
+				pos = linePosIn(barsFile, bar, first, last)
+			}
+			// the bar may come from a theme.yml of a theme this one extends
+			for _, name := range themeChain(theme.Name) {
+				if pos != barsFile {
+					break
+				}
+				if p := linePos(themeMetadataPath(name), bar); p != themeMetadataPath(name) {
+					pos = p
+				}
+			}
+			problems = append(problems, themeProblem{
+				Kind:    problemBar,
+				Pos:     pos,
+				Message: fmt.Sprintf("bar \"%s\" of theme \"%s\" is not defined in %s", bar, theme.Name, path),
+			})
+		}
+	}
+	if len(bars) == 0 {
+		bars = defined
+	}
+	_, err = exec.LookPath("fc-match")
+	checkFonts := err == nil
+	if !checkFonts {
+		log.Warnln("fc-match was not found, skipping font checks")
+	}
+	for _, font := range theme.Fonts {
+		if !checkFonts {
+			break
+		}
+		if err := checkFont(font); err != nil {
+			problems = append(problems, themeProblem{problemFont, themeValuePos(theme, metadata, font), err.Error()})
+		}
+	}
+	if hasPalette(theme) {
+		if _, err := resolvePalette(theme); err != nil {
+			problems = append(problems, themeProblem{problemPalette, themeKeyPos(theme, metadata, "palette"), err.Error()})
+		}
+	}
+	for _, err := range checkI3Appearance(theme) {
+		keys := append([]string{"i3"}, strings.Split(err.Key, ".")...)
+		problems = append(problems, themeProblem{problemI3, themeKeyPos(theme, metadata, keys...), err.Error()})
+	}
+	if family := pangoFamily(theme.I3.Font); family != "" && checkFonts {
+		if err := checkFont(family); err != nil {
+			problems = append(problems, themeProblem{problemFont, themeKeyPos(theme, metadata, "i3", "font"), err.Error()})
+		}
+	}
+	return append(problems, b.Check(path, bars, checkFonts)...)
+}
+
+func checkBarModules(c *lib.PolybarConfig, bar string, checked map[string]bool) []themeProblem {
+	var problems []themeProblem
+	for _, key := range []string{"modules-left", "modules-center", "modules-right"} {
+		v, ok := c.Lookup(bar, key)
+		if !ok {
+			continue
+		}
+		modules, err := c.Get(bar, key)
+		if err != nil {
+			problems = append(problems, themeProblem{problemConfig, v.Pos(), err.Error()})
+			continue
+		}
+		for _, m := range strings.Fields(modules) {
+			section := "module/" + m
+			if _, ok := c.Section(section); !ok {
+				problems = append(problems, themeProblem{problemModule, v.Pos(), fmt.Sprintf("module \"%s\" used by [%s] is not defined", m, bar)})
+				continue
+			}
+			if checked[section] {
+				continue
+			}
+			checked[section] = true
+			problems = append(problems, checkScriptModule(c, section)...)
+		}
+	}
+	return problems
+}
+
+// checkScriptModule checks that the commands of a custom/script module exist.
+func checkScriptModule(c *lib.PolybarConfig, module string) []themeProblem {
+	if t, _ := c.GetDefault(module, "type", ""); t != "custom/script" {
+		return nil
+	}
+	var problems []themeProblem
+	for _, key := range []string{"exec", "exec-if"} {
+		v, ok := c.Lookup(module, key)
+		if !ok {
+			continue
+		}
+		command, err := c.Get(module, key)
+		if err != nil {
+			problems = append(problems, themeProblem{problemConfig, v.Pos(), err.Error()})
+			continue
+		}
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			problems = append(problems, themeProblem{problemScript, v.Pos(), fmt.Sprintf("%s of [%s] is empty", key, module)})
+			continue
+		}
+		if err := checkExecutable(fields[0]); err != nil {
+			problems = append(problems, themeProblem{problemScript, v.Pos(), fmt.Sprintf("%s of [%s]: %s", key, module, err)})
+		}
+	}
+	return problems
+}
+
+// checkExecutable checks that a command can be run, either from $PATH or by its path.
+func checkExecutable(command string) error {
+	command = os.ExpandEnv(command)
+	if strings.HasPrefix(command, "~/") {
+		command = filepath.Join(Home, command[2:])
+	}
+	if !strings.Contains(command, "/") {
+		_, err := exec.LookPath(command)
+		if err != nil {
+			return fmt.Errorf("\"%s\" was not found in $PATH", command)
+		}
+		return nil
+	}
+	info, err := os.Stat(command)
+	if err != nil {
+		return fmt.Errorf("\"%s\" does not exist", command)
+	}
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		return fmt.Errorf("\"%s\" is not executable", command)
+	}
+	return nil
+}
+
+var fontKeyRe = regexp.MustCompile(`^font-\d+$`)
+
+func checkBarFonts(c *lib.PolybarConfig, bar string) []themeProblem {
+	var problems []themeProblem
+	for _, key := range c.Keys(bar) {
+		if !fontKeyRe.MatchString(key) {
+			continue
+		}
+		v, _ := c.Lookup(bar, key)
+		font, err := c.Get(bar, key)
+		if err != nil {
+			problems = append(problems, themeProblem{problemConfig, v.Pos(), err.Error()})
+			continue
+		}
+		if err := checkFont(font); err != nil {
+			problems = append(problems, themeProblem{problemFont, v.Pos(), err.Error()})
+		}
+	}
+	return problems
+}
+
+// checkFont checks that fontconfig resolves a polybar font to the requested family,
+// rather than falling back to another font.
+// example: "Iosevka Nerd Font:style=Medium:size=10;3" -> Iosevka Nerd Font
+func checkFont(font string) error {
+	pattern := strings.SplitN(font, ";", 2)[0]
+	family := strings.TrimSpace(strings.SplitN(pattern, ":", 2)[0])
+	if family == "" {
+		return nil
+	}
+	out, err := exec.Command("fc-match", "-f", "%{family}", pattern).Output()
+	if err != nil {
+		return fmt.Errorf("fc-match failed for font \"%s\": %s", pattern, err)
+	}
+	for _, f := range strings.Split(string(out), ",") {
+		if strings.EqualFold(strings.TrimSpace(f), family) {
+			return nil
+		}
+	}
+	return fmt.Errorf("font \"%s\" is not installed, fontconfig falls back to \"%s\"", family, string(out))
+}
+
+// linePos finds the line of a bar name in a yaml file, for error messages.
+func linePos(file, bar string) string {
+	return linePosIn(file, bar, 1, 0)
+}
+
+// linePosIn finds the line of a bar name between the lines first and last of
+// a yaml file. A last of 0 is the end of the file.
+func linePosIn(file, bar string, first, last int) string {
+	f, err := os.Open(file)
+	if err != nil {
+		return file
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if line < first {
+			continue
+		}
+		if last > 0 && line > last {
+			break
+		}
+		text := strings.TrimSpace(scanner.Text())
+		// the bar may be quoted
+		text = strings.Replace(strings.Replace(text, `"`, "", -1), "'", "", -1)
+		if text == "- "+bar || strings.HasSuffix(text, ": "+bar) {
+			return fmt.Sprintf("%s:%d", file, line)
+		}
+	}
+	return file
+}
+
+// themeEntryLines returns the first and last line of the entry of a theme in
+// polybar.themes of current_settings.yml, or 0, 0 if it has none.
+func themeEntryLines(file, name string) (int, int) {
+	themesLine, found := keyLine(file, []string{"polybar", "themes"})
+	if found < 2 {
+		return 0, 0
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return 0, 0
+	}
+	lines := strings.Split(string(data), "\n")
+	raw := lines[themesLine-1]
+	themesIndent := len(raw) - len(strings.TrimLeft(raw, " "))
+	itemIndent, entryIndent := -1, 0
+	start, end, match := 0, 0, false
+	for i := themesLine; i < len(lines); i++ {
+		raw := strings.TrimRight(lines[i], " ")
+		text := strings.TrimSpace(raw)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		indent := len(raw) - len(strings.TrimLeft(raw, " "))
+		item := text == "-" || strings.HasPrefix(text, "- ")
+		if itemIndent < 0 {
+			// the list may be indented as far as themes itself
+			if !item || indent < themesIndent {
+				break
+			}
+			itemIndent = indent
+		}
+		// the list ends at the first line that is neither an item nor in one
+		if indent < itemIndent || indent == itemIndent && !item {
+			break
+		}
+		if indent == itemIndent {
+			if match {
+				break
+			}
+			rest := text[1:]
+			text = strings.TrimSpace(rest)
+			start, entryIndent = i+1, indent+1+len(rest)-len(strings.TrimLeft(rest, " "))
+			indent = entryIndent
+		}
+		if indent == entryIndent && strings.HasPrefix(text, "name:") {
+			match = strings.Trim(strings.TrimSpace(text[len("name:"):]), `"'`) == name
+		}
+		end = i + 1
+	}
+	if !match {
+		return 0, 0
+	}
+	return start, end
+}
+
+// themeValuePos finds the line of a list item, like a font, in the theme.yml
+// of the theme or of the themes it extends. It returns fallback if no
+// theme.yml has it.
+func themeValuePos(theme Theme, fallback, value string) string {
+	for _, name := range themeChain(theme.Name) {
+		path := themeMetadataPath(name)
+		if pos := linePos(path, value); pos != path {
+			return pos
+		}
+	}
+	return fallback
+}
+
+// themeKeyPos finds the line of a setting, e.g. i3, colors, focused, in the
+// theme.yml of the theme or of the themes it extends. If no theme.yml has the
+// setting, the line of the closest key above it is returned, or else fallback.
+func themeKeyPos(theme Theme, fallback string, keys ...string) string {
+	pos, best := fallback, 0
+	for _, name := range themeChain(theme.Name) {
+		path := themeMetadataPath(name)
+		if line, found := keyLine(path, keys); found > best {
+			pos, best = fmt.Sprintf("%s:%d", path, line), found
+		}
+	}
+	return pos
+}
+
+// keyLine finds the line of a nested key in a yaml file. It returns the line
+// of the deepest key found, and how many of the keys were found.
+func keyLine(file string, keys []string) (int, int) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	line, keyLine, found, indent := 0, 0, 0, 0
+	for scanner.Scan() && found < len(keys) {
+		line++
+		raw := strings.TrimRight(scanner.Text(), " ")
+		text := strings.TrimSpace(raw)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		i := len(raw) - len(strings.TrimLeft(raw, " "))
+		// top level keys aren't indented, nested keys are indented further
+		// than the key they are in, which ends at the next line that isn't
+		if found == 0 && i > 0 {
+			continue
+		}
+		if found > 0 && i <= indent {
+			break
+		}
+		colon := strings.Index(text, ":")
+		if colon < 0 {
+			continue
+		}
+		key := strings.Trim(strings.TrimSpace(text[:colon]), `"'`)
+		// viper lowercases keys
+		if strings.EqualFold(key, keys[found]) {
+			keyLine, indent = line, i
+			found++
+		}
+	}
+	return keyLine, found
+}
@@ -0,0 +1,73 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const checkSettings = `displays:
+  current: home.sh
+polybar:
+  theme: nord
+  themes:
+  - name: solarized
+    bars:
+    - main
+  # nord has its own bars
+  - name: nord
+    bars:
+    - main
+    - side
+    variants:
+      laptop:
+        roles:
+          primary: [main]
+  - bars:
+    - "side"
+    name: "gruvbox"
+  themes_directory: .config/polybar/themes
+sound:
+  port: speakers
+`
+
+func TestThemeEntryPos(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dot-settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "current_settings.yml")
+	if err := ioutil.WriteFile(file, []byte(checkSettings), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		theme       string
+		first, last int
+		bar         string
+		want        string
+	}{
+		{"solarized", 6, 8, "main", file + ":8"},
+		{"nord", 10, 17, "main", file + ":12"},
+		{"nord", 10, 17, "side", file + ":13"},
+		{"gruvbox", 18, 20, "side", file + ":19"},
+		{"gruvbox", 18, 20, "main", file},
+		{"dracula", 0, 0, "main", file},
+	}
+	for _, tt := range tests {
+		first, last := themeEntryLines(file, tt.theme)
+		if first != tt.first || last != tt.last {
+			t.Errorf("themeEntryLines(%s) = %d, %d, want %d, %d", tt.theme, first, last, tt.first, tt.last)
+		}
+		if first == 0 {
+			continue
+		}
+		if got := linePosIn(file, tt.bar, first, last); got != tt.want {
+			t.Errorf("bar %s of %s: got %s, want %s", tt.bar, tt.theme, got, tt.want)
+		}
+	}
+}
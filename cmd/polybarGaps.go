@@ -0,0 +1,216 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/patrick-motard/dot/lib"
+	"go.i3wm.org/i3"
+)
+
+// edgeGaps is the room bars take at the top and bottom of an output, in pixels.
+type edgeGaps struct {
+	Top    int
+	Bottom int
+}
+
+// barGaps computes how much room the bars take on each output. Only bars with
+// override-redirect count: i3 docks other bars and makes room for them itself.
+func barGaps(c *lib.PolybarConfig, instances []barInstance, env map[string]string) (map[string]edgeGaps, error) {
+	outputs, err := i3.GetOutputs()
+	if err != nil {
+		return nil, err
+	}
+	rects := map[string]i3.Rect{}
+	primary := ""
+	for _, o := range outputs {
+		if !o.Active {
+			continue
+		}
+		rects[o.Name] = o.Rect
+		if o.Primary || primary == "" {
+			primary = o.Name
+		}
+	}
+
+	gaps := map[string]edgeGaps{}
+	for _, i := range instances {
+		section := "bar/" + i.Bar
+		monitor := barMonitor(c, i, env, primary)
+		restore := setBarEnv(c, env, i.Monitor)
+		redirect, _ := c.GetDefault(section, "override-redirect", "false")
+		if !parseBool(redirect) {
+			restore()
+			continue
+		}
+		rect, ok := rects[monitor]
+		if !ok {
+			restore()
+			log.Warnf("Bar '%s' is on output \"%s\", which i3 doesn't know, not making room for it", i, monitor)
+			continue
+		}
+		size, err := barSize(c, section, int(rect.Height))
+		bottom, _ := c.GetDefault(section, "bottom", "false")
+		restore()
+		if err != nil {
+			return nil, fmt.Errorf("bar '%s': %s", i, err)
+		}
+
+		g := gaps[monitor]
+		if parseBool(bottom) && size > g.Bottom {
+			g.Bottom = size
+		} else if !parseBool(bottom) && size > g.Top {
+			g.Top = size
+		}
+		gaps[monitor] = g
+	}
+	return gaps, nil
+}
+
+// setBarEnv makes ${env:...} references of the config resolve like they do
+// for a bar launched by dot, and returns a func that undoes it.
+func setBarEnv(c *lib.PolybarConfig, env map[string]string, monitor string) func() {
+	lookupEnv := c.LookupEnv
+	c.LookupEnv = func(name string) (string, bool) {
+		if name == "MONITOR" && monitor != "" {
+			return monitor, true
+		}
+		if v, ok := env[name]; ok {
+			return v, true
+		}
+		return lookupEnv(name)
+	}
+	return func() { c.LookupEnv = lookupEnv }
+}
+
+// barSize returns the room a bar takes from the edge of a monitor that is
+// height pixels high: its offset, its height and its borders.
+func barSize(c *lib.PolybarConfig, section string, height int) (int, error) {
+	dpi := 96
+	if v, err := c.GetDefault(section, "dpi-y", ""); err == nil && v != "" {
+		if d, err := strconv.Atoi(v); err == nil && d > 0 {
+			dpi = d
+		}
+	}
+	border, _ := c.GetDefault(section, "border-size", "0")
+	size := 0
+	for _, key := range []string{"offset-y", "height", "border-top-size", "border-bottom-size"} {
+		def := "0"
+		if strings.HasPrefix(key, "border-") {
+			def = border
+		}
+		v, err := c.GetDefault(section, key, def)
+		if err != nil {
+			return 0, err
+		}
+		n, err := parseBarExtent(v, height, dpi)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s \"%s\": %s", key, v, err)
+		}
+		if n > 0 {
+			size += n
+		}
+	}
+	return size, nil
+}
+
+// parseBarExtent converts a polybar size to pixels. Sizes are pixels, points,
+// a percentage of the monitor, or a percentage with an offset.
+// example: 30, 30px, 10pt, 3%, 100%:-20
+func parseBarExtent(v string, total, dpi int) (int, error) {
+	size := 0.0
+	for _, part := range strings.Split(strings.TrimSpace(v), ":") {
+		part = strings.TrimSpace(part)
+		unit := 1.0
+		switch {
+		case strings.HasSuffix(part, "%"):
+			part, unit = strings.TrimSuffix(part, "%"), float64(total)/100
+		case strings.HasSuffix(part, "pt"):
+			part, unit = strings.TrimSuffix(part, "pt"), float64(dpi)/72
+		case strings.HasSuffix(part, "px"):
+			part = strings.TrimSuffix(part, "px")
+		}
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, err
+		}
+		size += n * unit
+	}
+	return int(size + 0.5), nil
+}
+
+// parseBool parses a boolean the way polybar does.
+func parseBool(v string) bool {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "true", "yes", "on", "1":
+		return true
+	}
+	return false
+}
+
+// setI3Gaps sets a gap on every output. Outputs that need a different gap than
+// the primary output get it set on each of their workspaces, which means
+// focusing them, so the focused and visible workspaces are restored afterwards.
+// Workspaces created later get the gap of the primary output.
+func setI3Gaps(side string, sizes map[string]int, def int) error {
+	outputs, err := i3.GetOutputs()
+	if err != nil {
+		return err
+	}
+	base := def
+	for _, o := range outputs {
+		if o.Active && o.Primary {
+			if size, ok := sizes[o.Name]; ok {
+				base = size
+			}
+		}
+	}
+	if _, err := i3.RunCommand(fmt.Sprintf("gaps %s all set %d", side, base)); err != nil {
+		return err
+	}
+
+	workspaces, err := i3.GetWorkspaces()
+	if err != nil {
+		return err
+	}
+	commands := map[string]string{}
+	for _, ws := range workspaces {
+		size, ok := sizes[ws.Output]
+		if !ok {
+			size = def
+		}
+		if size != base {
+			commands[ws.Name] = fmt.Sprintf("gaps %s current set %d", side, size)
+		}
+	}
+	return runOnWorkspaces(workspaces, commands)
+}
+
+// runOnWorkspaces runs i3 commands on workspaces, by workspace name. Each
+// workspace is focused for its command, so the focused and visible workspaces
+// are restored afterwards.
+func runOnWorkspaces(workspaces []i3.Workspace, commands map[string]string) error {
+	var run, restore []string
+	focused := ""
+	for _, ws := range workspaces {
+		if c, ok := commands[ws.Name]; ok {
+			run = append(run, fmt.Sprintf("workspace --no-auto-back-and-forth %q; %s", ws.Name, c))
+		}
+		if ws.Focused {
+			focused = ws.Name
+		} else if ws.Visible {
+			restore = append(restore, fmt.Sprintf("workspace --no-auto-back-and-forth %q", ws.Name))
+		}
+	}
+	if len(run) == 0 {
+		return nil
+	}
+	if focused != "" {
+		restore = append(restore, fmt.Sprintf("workspace --no-auto-back-and-forth %q", focused))
+	}
+	_, err := i3.RunCommand(strings.Join(append(run, restore...), "; "))
+	return err
+}
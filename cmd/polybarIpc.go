@@ -0,0 +1,185 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/patrick-motard/dot/lib"
+)
+
+// Polybar 3.6 and newer listen on a unix socket per bar. Messages start with a
+// header of "polyipc", a version byte, the payload size and the message type.
+var polybarIPCMagic = []byte("polyipc")
+
+const (
+	polybarIPCVersion = 0
+	polybarIPCCommand = 0
+	polybarIPCAction  = 1
+	polybarIPCOK      = 0
+	polybarIPCTimeout = 2 * time.Second
+)
+
+// polybarMessage is a message for polybar's IPC. Older polybar versions read
+// messages from a named pipe instead of a socket, in their own format.
+type polybarMessage struct {
+	Type    byte
+	Payload string
+	// Legacy is the message in the format of the named pipe, e.g. "cmd:restart".
+	Legacy string
+}
+
+func polybarCommand(command string) polybarMessage {
+	return polybarMessage{Type: polybarIPCCommand, Payload: command, Legacy: "cmd:" + command}
+}
+
+// polybarHook triggers hook n (counting from 1) of a custom/ipc module.
+func polybarHook(module string, n int) polybarMessage {
+	return polybarMessage{
+		Type:    polybarIPCAction,
+		Payload: fmt.Sprintf("#%s.hook.%d", module, n-1),
+		Legacy:  fmt.Sprintf("hook:module/%s%d", module, n),
+	}
+}
+
+// polybarAction triggers a module action, e.g. "#date.toggle".
+func polybarAction(action string) polybarMessage {
+	return polybarMessage{Type: polybarIPCAction, Payload: action, Legacy: "action:" + action}
+}
+
+// polybarSocket returns the path of the IPC socket of the polybar process with the given pid.
+func polybarSocket(pid int) string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("polybar-%d", os.Getuid()))
+	} else {
+		dir = filepath.Join(dir, "polybar")
+	}
+	return filepath.Join(dir, fmt.Sprintf("ipc.%d.sock", pid))
+}
+
+// polybarFifo returns the path of the named pipe older polybar versions read messages from.
+func polybarFifo(pid int) string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("polybar_mqueue.%d", pid))
+}
+
+// notifyPolybar runs the hooks configured in polybar.hooks for an event, so that
+// modules showing dot's state can update. Errors are only logged, the bars may
+// not be running or may not have the module.
+func notifyPolybar(event string) {
+	for _, h := range Config.Polybar.Hooks[event] {
+		if err := sendPolybarMessages("", polybarHook(h.Module, h.Hook)); err != nil {
+			log.Debugf("Polybar hook %s/%d for %s: %s", h.Module, h.Hook, event, err)
+		}
+	}
+}
+
+// hasPolybarIPC reports whether a bar is configured with IPC enabled.
+func hasPolybarIPC(c *lib.PolybarConfig, bar string) bool {
+	v, _ := c.GetDefault("bar/"+bar, "enable-ipc", "false")
+	return v == "true"
+}
+
+// sendPolybarMessages sends a message to every bar dot started. If bar isn't
+// empty, only instances of that bar get the message.
+func sendPolybarMessages(bar string, m polybarMessage) error {
+	s, err := readPolybarState()
+	if err != nil {
+		return err
+	}
+	sent := 0
+	var failed []string
+	for _, name := range s.barNames() {
+		if bar != "" && bar != name && bar != parseBarInstance(name).Bar {
+			continue
+		}
+		b := s.Bars[name]
+		if !processAlive(b.Pid) {
+			continue
+		}
+		if err := sendPolybarMessage(b.Pid, m); err != nil {
+			log.Debugf("Sending %q to bar '%s' failed: %s", m.Payload, name, err)
+			failed = append(failed, fmt.Sprintf("%s: %s", name, err))
+			continue
+		}
+		sent++
+	}
+	if sent == 0 && len(failed) == 0 {
+		if bar != "" {
+			return fmt.Errorf("bar '%s' is not running", bar)
+		}
+		return fmt.Errorf("no bars are managed by dot")
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to send message to %d bar(s):\n%s", len(failed), strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+// sendPolybarMessage sends a message to the polybar process with the given pid.
+func sendPolybarMessage(pid int, m polybarMessage) error {
+	if _, err := os.Stat(polybarSocket(pid)); err == nil {
+		return sendPolybarSocket(polybarSocket(pid), m)
+	}
+	if info, err := os.Stat(polybarFifo(pid)); err == nil && info.Mode()&os.ModeNamedPipe != 0 {
+		return sendPolybarFifo(polybarFifo(pid), m)
+	}
+	return fmt.Errorf("polybar (pid %d) has no IPC socket, is enable-ipc set?", pid)
+}
+
+func sendPolybarSocket(path string, m polybarMessage) error {
+	conn, err := net.DialTimeout("unix", path, polybarIPCTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(polybarIPCTimeout))
+
+	var msg bytes.Buffer
+	msg.Write(polybarIPCMagic)
+	msg.WriteByte(polybarIPCVersion)
+	binary.Write(&msg, binary.LittleEndian, uint32(len(m.Payload)))
+	msg.WriteByte(m.Type)
+	msg.WriteString(m.Payload)
+	if _, err := conn.Write(msg.Bytes()); err != nil {
+		return err
+	}
+
+	// the reply has the same header, its payload is an error message if the type isn't OK
+	header := make([]byte, len(polybarIPCMagic)+1+4+1)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("no reply from %s: %s", path, err)
+	}
+	if !bytes.Equal(header[:len(polybarIPCMagic)], polybarIPCMagic) {
+		return fmt.Errorf("invalid reply from %s", path)
+	}
+	size := binary.LittleEndian.Uint32(header[len(polybarIPCMagic)+1:])
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return fmt.Errorf("no reply from %s: %s", path, err)
+	}
+	if header[len(header)-1] != polybarIPCOK {
+		return fmt.Errorf("polybar: %s", payload)
+	}
+	return nil
+}
+
+func sendPolybarFifo(path string, m polybarMessage) error {
+	// don't block if nobody is reading the pipe anymore
+	f, err := os.OpenFile(path, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(m.Legacy + "\n")
+	return err
+}
@@ -0,0 +1,168 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// What a dot command does when another one is loading bars.
+const (
+	ifRunningWait   = "wait"
+	ifRunningCancel = "cancel"
+	ifRunningFail   = "fail"
+)
+
+// polybarLock keeps two dot commands from loading bars at the same time, e.g.
+// i3's exec_always and a display hook both running 'dot polybar'.
+type polybarLock struct {
+	f *os.File
+	// signals catches SIGTERM and SIGINT while the lock is held, see
+	// Cancelled.
+	signals chan os.Signal
+	// stop is the signal that was caught, if any.
+	stop os.Signal
+}
+
+// heldLock is the lock this dot command holds, loadPolybar asks it whether to
+// go on.
+var heldLock *polybarLock
+
+// errLoadCancelled is returned by loadPolybar when another dot command asked
+// it to stop before the running bars were touched.
+var errLoadCancelled = errors.New("cancelled by another dot command")
+
+func polybarLockFile() string {
+	return filepath.Join(runtimeDir(), "polybar.lock")
+}
+
+// lockPolybar takes the lock. If another dot command holds it, it waits for
+// it, asks it to stop first, or fails, depending on ifRunning.
+func lockPolybar(ifRunning string) (*polybarLock, error) {
+	if err := os.MkdirAll(runtimeDir(), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(polybarLockFile(), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	l := &polybarLock{f: f}
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil && err != syscall.EWOULDBLOCK {
+		f.Close()
+		return nil, err
+	}
+	if err == syscall.EWOULDBLOCK {
+		owner := l.owner()
+		switch ifRunning {
+		case ifRunningFail:
+			f.Close()
+			return nil, fmt.Errorf("dot is already loading bars (pid %d), try again later or use --if-running wait or cancel", owner)
+		case ifRunningCancel:
+			log.Infof("Cancelling dot (pid %d), which is loading bars", owner)
+			if owner > 0 {
+				syscall.Kill(owner, syscall.SIGTERM)
+			}
+		default:
+			log.Infof("Waiting for dot (pid %d) to finish loading bars", owner)
+		}
+		if err := l.wait(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	if err := f.Truncate(0); err != nil {
+		l.Unlock()
+		return nil, err
+	}
+	if _, err := f.WriteAt([]byte(fmt.Sprintf("%d\n", os.Getpid())), 0); err != nil {
+		l.Unlock()
+		return nil, err
+	}
+	// dot stops before it stops the running bars, or once the new bars are
+	// loaded, rather than in the middle of it, which could leave no bars and
+	// half set gaps
+	l.signals = make(chan os.Signal, 1)
+	signal.Notify(l.signals, syscall.SIGTERM, syscall.SIGINT)
+	heldLock = l
+	return l, nil
+}
+
+// wait blocks until the lock is free.
+func (l *polybarLock) wait() error {
+	for {
+		err := syscall.Flock(int(l.f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			return nil
+		}
+		if err != syscall.EWOULDBLOCK {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// Cancelled reports whether dot was asked to stop while it held the lock.
+func (l *polybarLock) Cancelled() bool {
+	if l == nil || l.signals == nil {
+		return false
+	}
+	if l.stop == nil {
+		select {
+		case l.stop = <-l.signals:
+		default:
+		}
+	}
+	return l.stop != nil
+}
+
+// owner returns the pid of the dot command that holds the lock, or 0.
+func (l *polybarLock) owner() int {
+	data, err := ioutil.ReadFile(l.f.Name())
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
+
+// Unlock releases the lock. The lock file is left in place, removing it would
+// let two commands lock different files. If dot was asked to stop while it
+// held the lock, it exits now.
+func (l *polybarLock) Unlock() {
+	cancelled := l.Cancelled()
+	l.f.Truncate(0)
+	syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN)
+	l.f.Close()
+	if heldLock == l {
+		heldLock = nil
+	}
+	if l.signals == nil {
+		return
+	}
+	signal.Stop(l.signals)
+	if cancelled {
+		log.Infof("Stopping (%s)", l.stop)
+		os.Exit(1)
+	}
+}
+
+// checkIfRunning validates the value of an --if-running flag.
+func checkIfRunning(ifRunning string) error {
+	switch ifRunning {
+	case ifRunningWait, ifRunningCancel, ifRunningFail:
+		return nil
+	}
+	return fmt.Errorf("invalid --if-running \"%s\", expected wait, cancel or fail", ifRunning)
+}
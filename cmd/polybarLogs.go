@@ -0,0 +1,251 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	_logsFollow bool
+	_logsLines  int
+	_logsLevel  string
+)
+
+var polybarLogsCmd = &cobra.Command{
+	Use:   "logs [bar]",
+	Short: "Show the output of the bars dot runs.",
+	Long: `Prints the last lines of the log files dot writes for its bars, in
+$XDG_STATE_HOME/dot/polybar/<bar>.log. Without a bar, the logs of every bar are shown.
+
+A bar is either a bar name, which matches every monitor the bar runs on, or an
+instance like main@DP-4. Logs are rotated once they reach 1 MiB.
+
+--level hides lines below a severity: trace, info, notice, warn or error.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		level, ok := logLevels[_logsLevel]
+		if !ok {
+			log.Fatalf("Unknown level \"%s\", expected trace, info, notice, warn or error", _logsLevel)
+		}
+		bar := ""
+		if len(args) == 1 {
+			bar = args[0]
+		}
+		files, err := polybarLogFiles(bar)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(files) == 0 {
+			if bar != "" {
+				log.Fatalf("No logs found for bar \"%s\" in %s", bar, polybarLogDir())
+			}
+			log.Fatalf("No logs found in %s", polybarLogDir())
+		}
+		if err := tailLogs(files, _logsLines, level, _logsFollow); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	polybarCmd.AddCommand(polybarLogsCmd)
+	polybarLogsCmd.Flags().BoolVarP(&_logsFollow, "follow", "f", false, "Keep printing lines as they are written.")
+	polybarLogsCmd.Flags().IntVarP(&_logsLines, "lines", "n", 20, "Number of lines to show.")
+	polybarLogsCmd.Flags().StringVarP(&_logsLevel, "level", "L", "trace", "Only show lines of this severity or worse.")
+}
+
+// severities of polybar log lines, from least to most severe
+const (
+	levelTrace = iota
+	levelInfo
+	levelNotice
+	levelWarn
+	levelError
+)
+
+var logLevels = map[string]int{
+	"trace":  levelTrace,
+	"info":   levelInfo,
+	"notice": levelNotice,
+	"warn":   levelWarn,
+	"error":  levelError,
+}
+
+var (
+	ansiRe = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	// example: 2019-05-01T10:00:00+02:00 warn: No monitor specified
+	logLineRe = regexp.MustCompile(`^(\d{4}-\S+) (?:(trace|info|notice|warn|error): )?`)
+)
+
+// logLine is a line of a bar's log.
+type logLine struct {
+	Bar   string
+	Time  string
+	Level int
+	Text  string
+}
+
+// parseLogLine splits a log line into its timestamp, severity and message.
+// Lines without a polybar prefix, like the output of scripts, count as info.
+func parseLogLine(bar, line string) logLine {
+	line = ansiRe.ReplaceAllString(line, "")
+	l := logLine{Bar: bar, Level: levelInfo, Text: line}
+	m := logLineRe.FindStringSubmatch(line)
+	if m == nil {
+		return l
+	}
+	l.Time = m[1]
+	l.Text = line[len(m[0]):]
+	if m[2] != "" {
+		l.Level = logLevels[m[2]]
+		l.Text = m[2] + ": " + l.Text
+	}
+	return l
+}
+
+// polybarLogFiles returns the log files of a bar, or of every bar if bar is
+// empty. The supervisor's own log is only included when all logs are shown.
+func polybarLogFiles(bar string) ([]string, error) {
+	var patterns []string
+	switch {
+	case bar == "":
+		patterns = []string{"*.log"}
+	case strings.Contains(bar, "@"):
+		patterns = []string{bar + ".log"}
+	default:
+		patterns = []string{bar + ".log", bar + "@*.log"}
+	}
+	var files []string
+	for _, p := range patterns {
+		m, err := filepath.Glob(filepath.Join(polybarLogDir(), p))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, m...)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// logBar returns the bar a log file belongs to.
+func logBar(file string) string {
+	return strings.TrimSuffix(filepath.Base(file), ".log")
+}
+
+// tailLogs prints the last n lines of the files, oldest first. Lines of
+// several files are ordered by their timestamps and prefixed with the bar.
+func tailLogs(files []string, n, level int, follow bool) error {
+	var lines []logLine
+	offsets := map[string]int64{}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		read, offset, err := readLogLines(f, logBar(file), level)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		lines = append(lines, read...)
+		offsets[file] = offset
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Time < lines[j].Time })
+	if n >= 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	prefix := len(files) > 1
+	for _, l := range lines {
+		printLogLine(l, prefix)
+	}
+	if !follow {
+		return nil
+	}
+	return followLogs(files, offsets, level, prefix)
+}
+
+// readLogLines reads the complete lines of r and returns them with the offset
+// after the last complete line.
+func readLogLines(r io.Reader, bar string, level int) ([]logLine, int64, error) {
+	var lines []logLine
+	var offset int64
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err == io.EOF {
+			// a partial line is read again once it is complete
+			return lines, offset, nil
+		}
+		if err != nil {
+			return lines, offset, err
+		}
+		offset += int64(len(line))
+		if l := parseLogLine(bar, strings.TrimRight(line, "\n")); l.Level >= level {
+			lines = append(lines, l)
+		}
+	}
+}
+
+// followLogs polls the files for new lines. A file that got smaller or was
+// replaced has been rotated and is read from the start.
+func followLogs(files []string, offsets map[string]int64, level int, prefix bool) error {
+	infos := map[string]os.FileInfo{}
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			infos[file] = info
+		}
+	}
+	for range time.Tick(500 * time.Millisecond) {
+		for _, file := range files {
+			info, err := os.Stat(file)
+			if err != nil {
+				// rotated away, the supervisor creates the file again
+				continue
+			}
+			if old, ok := infos[file]; !ok || !os.SameFile(old, info) || info.Size() < offsets[file] {
+				offsets[file] = 0
+			}
+			infos[file] = info
+			if info.Size() == offsets[file] {
+				continue
+			}
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			if _, err := f.Seek(offsets[file], io.SeekStart); err != nil {
+				f.Close()
+				return err
+			}
+			lines, offset, err := readLogLines(f, logBar(file), level)
+			f.Close()
+			if err != nil {
+				return err
+			}
+			offsets[file] += offset
+			for _, l := range lines {
+				printLogLine(l, prefix)
+			}
+		}
+	}
+	return nil
+}
+
+func printLogLine(l logLine, prefix bool) {
+	if prefix {
+		fmt.Printf("%s %s %s\n", l.Bar, l.Time, l.Text)
+		return
+	}
+	fmt.Printf("%s %s\n", l.Time, l.Text)
+}
@@ -0,0 +1,283 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	_moduleFormat string
+	_moduleTail   bool
+	_moduleClick  string
+)
+
+var polybarModuleCmd = &cobra.Command{
+	Use:   "module <name>",
+	Short: "Print dot's state for a polybar custom/script module.",
+	Long: `Prints one line for a polybar custom/script module. Modules:
+  sound       the sound port and the volume of the Master control (amixer)
+  displays    the name of the current display layout
+  theme       the current polybar theme
+  brightness  the brightness of the backlight, in percent
+  updates     the number of pending pacman updates (checkupdates)
+
+--format is a Go template with these fields:
+  sound       .Port .Volume .Muted
+  displays    .Layout
+  theme       .Theme .Description
+  brightness  .Brightness .Device
+  updates     .Count .Packages
+
+With --tail, dot keeps running and prints a new line whenever the state changes,
+for modules with 'tail = true'. --click handles a click on the module: left,
+middle, right, up or down (scrolling).
+
+[module/sound]
+type = custom/script
+exec = dot polybar module sound --tail
+tail = true
+click-left = dot polybar module sound --click left
+scroll-up = dot polybar module sound --click up
+scroll-down = dot polybar module sound --click down`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: barModuleNames(),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, ok := barModules[args[0]]
+		if !ok {
+			log.Fatalf("Unknown module \"%s\", expected one of: %s", args[0], strings.Join(barModuleNames(), ", "))
+		}
+		if _moduleClick != "" {
+			if err := clickModule(m, _moduleClick); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+		format := m.Format
+		if _moduleFormat != "" {
+			format = _moduleFormat
+		}
+		t, err := template.New(args[0]).Parse(format)
+		if err != nil {
+			log.Fatalf("Invalid --format: %s", err)
+		}
+		if !_moduleTail {
+			fmt.Println(renderModule(m, t))
+			return
+		}
+		if err := tailModule(m, t); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	polybarCmd.AddCommand(polybarModuleCmd)
+	polybarModuleCmd.Flags().StringVarP(&_moduleFormat, "format", "f", "", "Go template for the output, e.g. '{{.Volume}}%'.")
+	polybarModuleCmd.Flags().BoolVar(&_moduleTail, "tail", false, "Keep running and print a line whenever the state changes.")
+	polybarModuleCmd.Flags().StringVar(&_moduleClick, "click", "", "Handle a click: left, middle, right, up or down.")
+}
+
+// barModule is a module dot serves to polybar.
+type barModule struct {
+	// Format is the default template for the output.
+	Format string
+	// State returns the values the template is executed with.
+	State func() (interface{}, error)
+	// Clicks maps a mouse button to what a click on the module does.
+	Clicks map[string]func() error
+	// Watch signals changed whenever the state may have changed. It runs
+	// until dot exits.
+	Watch func(changed chan<- struct{}) error
+}
+
+var mouseButtons = []string{"left", "middle", "right", "up", "down"}
+
+func barModuleNames() []string {
+	var names []string
+	for name := range barModules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// renderModule returns the module's output. Errors are logged to stderr,
+// which polybar keeps out of the bar, and leave the module empty.
+func renderModule(m barModule, t *template.Template) string {
+	// the settings may have changed since dot started
+	if err := rereadChangedConfig(); err != nil {
+		log.Errorf("Failed to read %s: %s", cfgFile, err)
+	}
+	state, err := m.State()
+	if err != nil {
+		log.Errorln(err)
+		return ""
+	}
+	var out bytes.Buffer
+	if err := t.Execute(&out, state); err != nil {
+		log.Errorln(err)
+		return ""
+	}
+	// polybar reads one line per update
+	return strings.TrimSpace(strings.Replace(out.String(), "\n", " ", -1))
+}
+
+// configModTime is when the config was last changed, as of the last time
+// rereadChangedConfig read it.
+var configModTime time.Time
+
+// rereadChangedConfig reads the config again if it changed since it was last
+// read by a module. Modules like brightness render every second.
+func rereadChangedConfig() error {
+	info, err := os.Stat(cfgFile)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(configModTime) {
+		return nil
+	}
+	if err := rereadConfig(); err != nil {
+		return err
+	}
+	configModTime = info.ModTime()
+	return nil
+}
+
+// tailModule prints the module's output every time it changes.
+func tailModule(m barModule, t *template.Template) error {
+	changed := make(chan struct{}, 1)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- m.Watch(changed)
+	}()
+	last := ""
+	for first := true; ; first = false {
+		if out := renderModule(m, t); first || out != last {
+			fmt.Println(out)
+			last = out
+		}
+		select {
+		case <-changed:
+		case err := <-errs:
+			return err
+		}
+	}
+}
+
+func clickModule(m barModule, button string) error {
+	for _, b := range mouseButtons {
+		if b == button {
+			if click, ok := m.Clicks[button]; ok {
+				return click()
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown button \"%s\", expected one of: %s", button, strings.Join(mouseButtons, ", "))
+}
+
+// notifyChanged tells a tailing module to update, without blocking if an
+// update is already pending.
+func notifyChanged(changed chan<- struct{}) {
+	select {
+	case changed <- struct{}{}:
+	default:
+	}
+}
+
+// watchPaths signals changed when one of the files changes, or when anything
+// in one of the directories changes.
+func watchPaths(changed chan<- struct{}, paths ...string) error {
+	w, err := newFileWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	files, dirs := map[string]bool{}, map[string]bool{}
+	for _, p := range paths {
+		p = absPath(p)
+		info, err := os.Stat(p)
+		if err == nil && info.IsDir() {
+			dirs[p] = true
+			err = w.add(p)
+		} else {
+			// editors replace files when saving, so watch the directory
+			files[p] = true
+			err = w.add(filepath.Dir(p))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for batch := range debounce(w.Events, 100*time.Millisecond) {
+		for _, p := range batch {
+			if files[p] || dirs[filepath.Dir(p)] {
+				notifyChanged(changed)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// watchCommand signals changed for every line the command prints.
+func watchCommand(changed chan<- struct{}, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		notifyChanged(changed)
+	}
+	return cmd.Wait()
+}
+
+// watchEvery signals changed every interval.
+func watchEvery(changed chan<- struct{}, interval time.Duration) error {
+	for range time.Tick(interval) {
+		notifyChanged(changed)
+	}
+	return nil
+}
+
+// watchAll runs the watchers until the first one fails.
+func watchAll(watchers ...func() error) error {
+	errs := make(chan error, len(watchers))
+	for _, w := range watchers {
+		go func(w func() error) {
+			errs <- w()
+		}(w)
+	}
+	return <-errs
+}
+
+// runDot runs a dot command, e.g. for a click on a module.
+func runDot(args ...string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if cfgFile != "" {
+		args = append(args, "--config", cfgFile)
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,232 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var barModules = map[string]barModule{
+	"sound": {
+		Format: "{{if .Muted}}muted{{else}}{{.Volume}}%{{end}} {{.Port}}",
+		State:  soundState,
+		Clicks: map[string]func() error{
+			"left": func() error { return amixer("set", "Master", "toggle") },
+			"up":   func() error { return amixer("set", "Master", "5%+") },
+			"down": func() error { return amixer("set", "Master", "5%-") },
+		},
+		Watch: func(changed chan<- struct{}) error {
+			return watchAll(
+				func() error { return watchPaths(changed, cfgFile) },
+				func() error {
+					// alsactl prints a line for every change of a mixer control
+					if _, err := exec.LookPath("alsactl"); err != nil {
+						log.Warnln("alsactl was not found, checking the volume every 2s")
+						return watchEvery(changed, 2*time.Second)
+					}
+					return watchCommand(changed, "alsactl", "monitor")
+				},
+			)
+		},
+	},
+	"displays": {
+		Format: "{{.Layout}}",
+		State: func() (interface{}, error) {
+			return struct{ Layout string }{Config.Displays.Current}, nil
+		},
+		Clicks: map[string]func() error{
+			"left": func() error { return runDot("displays", "select") },
+		},
+		Watch: func(changed chan<- struct{}) error {
+			return watchPaths(changed, cfgFile)
+		},
+	},
+	"theme": {
+		Format: "{{.Theme}}",
+		State:  themeState,
+		Clicks: map[string]func() error{
+			"left":  func() error { return runDot("polybar", "--select") },
+			"right": func() error { return runDot("polybar") },
+		},
+		Watch: func(changed chan<- struct{}) error {
+			return watchPaths(changed, cfgFile)
+		},
+	},
+	"brightness": {
+		Format: "{{.Brightness}}%",
+		State:  func() (interface{}, error) { return brightnessState() },
+		Clicks: map[string]func() error{
+			"up":   func() error { return changeBrightness(5) },
+			"down": func() error { return changeBrightness(-5) },
+		},
+		Watch: func(changed chan<- struct{}) error {
+			// the kernel doesn't report brightness changes made with the
+			// brightness keys, so the backlight is checked every second
+			return watchEvery(changed, time.Second)
+		},
+	},
+	"updates": {
+		Format: "{{if .Count}}{{.Count}} updates{{end}}",
+		State:  updatesState,
+		Clicks: map[string]func() error{
+			"left": func() error {
+				terminal := os.Getenv("TERMINAL")
+				if terminal == "" {
+					return fmt.Errorf("set $TERMINAL to run 'dot update' from the bar")
+				}
+				exe, err := os.Executable()
+				if err != nil {
+					return err
+				}
+				return exec.Command(terminal, "-e", exe, "update").Start()
+			},
+		},
+		Watch: func(changed chan<- struct{}) error {
+			return watchAll(
+				// pacman changes its database when packages are installed
+				func() error { return watchPaths(changed, "/var/lib/pacman/local") },
+				func() error { return watchEvery(changed, time.Hour) },
+			)
+		},
+	},
+}
+
+var (
+	// example: Front Left: Playback 39789 [61%] [-18.75dB] [on]
+	amixerVolumeRe = regexp.MustCompile(`\[(\d+)%\]`)
+	amixerSwitchRe = regexp.MustCompile(`\[(on|off)\]`)
+)
+
+func soundState() (interface{}, error) {
+	s := struct {
+		Port   string
+		Volume int
+		Muted  bool
+	}{Port: Config.Sound.Port}
+	out, err := exec.Command("amixer", "get", "Master").Output()
+	if err != nil {
+		return s, fmt.Errorf("amixer get Master failed: %s", err)
+	}
+	if m := amixerVolumeRe.FindSubmatch(out); m != nil {
+		s.Volume, _ = strconv.Atoi(string(m[1]))
+	}
+	if m := amixerSwitchRe.FindSubmatch(out); m != nil {
+		s.Muted = string(m[1]) == "off"
+	}
+	return s, nil
+}
+
+func amixer(args ...string) error {
+	out, err := exec.Command("amixer", append([]string{"-q"}, args...)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("amixer %s failed: %s\n%s", strings.Join(args, " "), err, out)
+	}
+	return nil
+}
+
+func themeState() (interface{}, error) {
+	FullThemesPath = Home + "/" + Config.Polybar.ThemesDirectory
+	t, err := loadTheme(Config.Polybar.Theme)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		Theme       string
+		Description string
+	}{Config.Polybar.Theme, t.Description}, nil
+}
+
+// backlight is a backlight device in /sys/class/backlight.
+type backlight struct {
+	Device     string
+	Brightness int // percent
+	dir        string
+	max        int
+	raw        int
+}
+
+func brightnessState() (backlight, error) {
+	var b backlight
+	dirs, _ := filepath.Glob("/sys/class/backlight/*")
+	if len(dirs) == 0 {
+		return b, fmt.Errorf("no backlight found in /sys/class/backlight")
+	}
+	b.dir, b.Device = dirs[0], filepath.Base(dirs[0])
+	var err error
+	if b.max, err = readInt(filepath.Join(b.dir, "max_brightness")); err != nil {
+		return b, err
+	}
+	if b.raw, err = readInt(filepath.Join(b.dir, "brightness")); err != nil {
+		return b, err
+	}
+	if b.max > 0 {
+		b.Brightness = (b.raw*100 + b.max/2) / b.max
+	}
+	return b, nil
+}
+
+// changeBrightness changes the brightness by percent. brightnessctl is used if
+// it is installed, since writing to /sys usually needs root.
+func changeBrightness(percent int) error {
+	if _, err := exec.LookPath("brightnessctl"); err == nil {
+		change := fmt.Sprintf("%d%%+", percent)
+		if percent < 0 {
+			change = fmt.Sprintf("%d%%-", -percent)
+		}
+		out, err := exec.Command("brightnessctl", "--quiet", "set", change).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("brightnessctl failed: %s\n%s", err, out)
+		}
+		return nil
+	}
+	b, err := brightnessState()
+	if err != nil {
+		return err
+	}
+	raw := b.raw + b.max*percent/100
+	if raw < 0 {
+		raw = 0
+	}
+	if raw > b.max {
+		raw = b.max
+	}
+	return ioutil.WriteFile(filepath.Join(b.dir, "brightness"), []byte(strconv.Itoa(raw)), 0644)
+}
+
+func readInt(path string) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+func updatesState() (interface{}, error) {
+	s := struct {
+		Count    int
+		Packages []string
+	}{}
+	out, err := exec.Command("checkupdates").Output()
+	if exit, ok := err.(*exec.ExitError); ok && exit.ExitCode() == 2 {
+		// checkupdates exits with 2 when there are no updates
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("checkupdates failed: %s", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			s.Packages = append(s.Packages, fields[0])
+		}
+	}
+	s.Count = len(s.Packages)
+	return s, nil
+}
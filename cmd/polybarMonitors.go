@@ -0,0 +1,137 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/patrick-motard/dot/lib"
+)
+
+// Roles a theme can assign bars to. Any other role is a display alias from
+// displays.aliases, or the name of an output.
+const (
+	rolePrimary   = "primary"
+	roleSecondary = "secondary"
+	roleAll       = "all"
+)
+
+// barInstance is one polybar process: a bar of the theme, optionally pinned to a monitor.
+type barInstance struct {
+	Bar     string
+	Monitor string
+}
+
+// String returns the name dot uses for the instance, e.g. "main@DP-4".
+func (b barInstance) String() string {
+	if b.Monitor == "" {
+		return b.Bar
+	}
+	return b.Bar + "@" + b.Monitor
+}
+
+func parseBarInstance(s string) barInstance {
+	i := strings.LastIndex(s, "@")
+	if i < 0 {
+		return barInstance{Bar: s}
+	}
+	return barInstance{Bar: s[:i], Monitor: s[i+1:]}
+}
+
+// active returns the connected and enabled displays, left to right.
+func (ds *displays) active() []display {
+	var active []display
+	for _, d := range ds.get() {
+		if d.active {
+			active = append(active, d)
+		}
+	}
+	return active
+}
+
+// monitorsForRole returns the displays a role applies to right now.
+func monitorsForRole(role string, ds *displays) []display {
+	active := ds.active()
+	if len(active) == 0 {
+		return nil
+	}
+	// X doesn't always have a primary output, use the leftmost display then.
+	primary := active[0]
+	for _, d := range active {
+		if d.primary {
+			primary = d
+		}
+	}
+
+	var matches []display
+	switch strings.ToLower(role) {
+	case rolePrimary:
+		matches = append(matches, primary)
+	case roleSecondary:
+		for _, d := range active {
+			if d.name != primary.name {
+				matches = append(matches, d)
+			}
+		}
+	case roleAll:
+		matches = active
+	default:
+		name := role
+		// viper lowercases keys, so aliases and output names are matched without case
+		for alias, output := range Config.Displays.Aliases {
+			if strings.EqualFold(alias, role) {
+				name = output
+			}
+		}
+		for _, d := range active {
+			if strings.EqualFold(d.name, name) {
+				matches = append(matches, d)
+			}
+		}
+	}
+	return matches
+}
+
+// roleBarInstances returns one instance per bar and matching monitor for the
+// theme's roles. Roles without a connected monitor are skipped.
+func roleBarInstances(theme Theme, ds *displays) []barInstance {
+	var roles []string
+	for role := range theme.Roles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	var instances []barInstance
+	seen := map[string]bool{}
+	for _, role := range roles {
+		monitors := monitorsForRole(role, ds)
+		if len(monitors) == 0 {
+			log.Infof("No monitor for role \"%s\", skipping its bars", role)
+			continue
+		}
+		for _, bar := range theme.Roles[role] {
+			for _, m := range monitors {
+				i := barInstance{Bar: bar, Monitor: m.name}
+				if !seen[i.String()] {
+					seen[i.String()] = true
+					instances = append(instances, i)
+				}
+			}
+		}
+	}
+	return instances
+}
+
+// hasMonitor reports whether a bar has a monitor to go on. Bars whose 'monitor'
+// setting resolves to nothing, e.g. ${env:MONITOR_LEFT} when there is no left
+// display, would otherwise end up on the wrong screen.
+func hasMonitor(c *lib.PolybarConfig, bar string, env map[string]string) bool {
+	raw, ok := c.Lookup("bar/"+bar, "monitor")
+	if !ok || raw.Value == "" {
+		return true
+	}
+	defer setBarEnv(c, env, "")()
+	monitor, err := c.Get("bar/"+bar, "monitor")
+	return err != nil || monitor != ""
+}
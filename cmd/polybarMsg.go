@@ -0,0 +1,71 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var _msgBar string
+
+var polybarMsgCmd = &cobra.Command{
+	Use:   "msg hook <module> <n> | action <action> | cmd <command>",
+	Short: "Send a message to the bars started by dot through polybar's IPC.",
+	Long: `Sends a message to every bar started by dot, or to the instances of one bar with --bar.
+The bars need 'enable-ipc = true'.
+
+Examples:
+dot polybar msg hook alsa 1        # run hook 1 of the custom/ipc module 'alsa'
+dot polybar msg action "#date.toggle"
+dot polybar msg --bar main cmd restart
+
+Commands are restart, quit, hide, show and toggle.`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := parsePolybarMessage(args)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := sendPolybarMessages(_msgBar, m); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	polybarCmd.AddCommand(polybarMsgCmd)
+	polybarMsgCmd.Flags().StringVarP(&_msgBar, "bar", "b", "", "Only send the message to this bar.")
+}
+
+var polybarCommands = []string{"restart", "quit", "hide", "show", "toggle"}
+
+func parsePolybarMessage(args []string) (polybarMessage, error) {
+	switch args[0] {
+	case "hook":
+		if len(args) != 3 {
+			return polybarMessage{}, fmt.Errorf("usage: hook <module> <n>")
+		}
+		n, err := strconv.Atoi(args[2])
+		if err != nil || n < 1 {
+			return polybarMessage{}, fmt.Errorf("hook number must be 1 or more, got \"%s\"", args[2])
+		}
+		return polybarHook(args[1], n), nil
+	case "action":
+		return polybarAction(strings.Join(args[1:], " ")), nil
+	case "cmd":
+		if len(args) != 2 {
+			return polybarMessage{}, fmt.Errorf("usage: cmd <command>")
+		}
+		for _, c := range polybarCommands {
+			if args[1] == c {
+				return polybarCommand(c), nil
+			}
+		}
+		return polybarMessage{}, fmt.Errorf("unknown command \"%s\", expected one of %s", args[1], strings.Join(polybarCommands, ", "))
+	}
+	return polybarMessage{}, fmt.Errorf("unknown message type \"%s\", expected hook, action or cmd", args[0])
+}
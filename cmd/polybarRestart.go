@@ -0,0 +1,50 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+var polybarRestartCmd = &cobra.Command{
+	Use:   "restart <bar>",
+	Short: "Restart a single bar started by dot.",
+	Long: `Stops the bar's polybar process and waits for dot's supervisor to start it again.
+Run 'dot polybar status' to see the names of the running bars.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := restartBar(args[0]); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	polybarCmd.AddCommand(polybarRestartCmd)
+}
+
+func restartBar(bar string) error {
+	s, err := readPolybarState()
+	if err != nil {
+		return err
+	}
+	if !processAlive(s.Supervisor) {
+		return fmt.Errorf("no bars are managed by dot, run 'dot polybar' first")
+	}
+	b, ok := s.Bars[bar]
+	if !ok {
+		return fmt.Errorf("bar '%s' is not managed by dot", bar)
+	}
+	if processAlive(b.Pid) {
+		log.Infof("Stopping bar '%s' (pid %d)", bar, b.Pid)
+		syscall.Kill(b.Pid, syscall.SIGTERM)
+		if !waitForExit(b.Pid, barStopTimeout) {
+			syscall.Kill(b.Pid, syscall.SIGKILL)
+		}
+	}
+	// the supervisor notices the exit and starts the bar again
+	return waitForBars(s.Supervisor, []string{bar}, nil)
+}
@@ -0,0 +1,51 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var polybarStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the bars dot is running and whether they are up.",
+	Long:  `Reads dot's polybar pid file and checks every bar recorded in it.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		s, err := readPolybarState()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !processAlive(s.Supervisor) && len(s.Bars) == 0 {
+			fmt.Println("No bars are managed by dot.")
+			return
+		}
+		status := "running"
+		if !processAlive(s.Supervisor) {
+			status = "not running"
+		}
+		fmt.Printf("theme: %s\nsupervisor: %d (%s)\n\n", s.Theme, s.Supervisor, status)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "BAR\tPID\tSTATUS\tUPTIME\tRESTARTS")
+		for _, name := range s.barNames() {
+			b := s.Bars[name]
+			status, uptime := "stopped", "-"
+			if processAlive(b.Pid) {
+				status = "running"
+				uptime = time.Since(b.Started).Round(time.Second).String()
+			}
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%d\n", name, b.Pid, status, uptime, b.Restarts)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	polybarCmd.AddCommand(polybarStatusCmd)
+}
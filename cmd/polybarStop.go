@@ -0,0 +1,32 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var polybarStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop all bars started by dot.",
+	Long:  `Stops dot's polybar supervisor and the bars it started, and removes i3bar bars dot added to i3. Bars not started by dot are left running.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := checkIfRunning(_ifRunning); err != nil {
+			log.Fatal(err)
+		}
+		lock, err := lockPolybar(_ifRunning)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer lock.Unlock()
+		if err := stopBars(); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	polybarCmd.AddCommand(polybarStopCmd)
+	polybarStopCmd.Flags().StringVar(&_ifRunning, "if-running", ifRunningWait, "What to do if another dot command is loading bars: wait, cancel or fail.")
+}
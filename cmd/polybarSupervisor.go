@@ -0,0 +1,392 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// A bar that stays up this long is considered healthy. Crashes after that
+	// reset the restart backoff.
+	barHealthyAfter = 2 * time.Second
+	barMinBackoff   = 1 * time.Second
+	barMaxBackoff   = 1 * time.Minute
+	// How long 'dot polybar' waits for the bars to become healthy before giving up.
+	barStartTimeout = 20 * time.Second
+	barStopTimeout  = 5 * time.Second
+)
+
+var _superviseTheme string
+
+// polybarState is written to the pid file by the supervisor. It is the only
+// record of which polybar processes were started by dot.
+type polybarState struct {
+	Supervisor int                  `json:"supervisor"`
+	Theme      string               `json:"theme"`
+	Bars       map[string]*barState `json:"bars"`
+}
+
+type barState struct {
+	Pid      int       `json:"pid"`
+	Started  time.Time `json:"started"`
+	Restarts int       `json:"restarts"`
+}
+
+var polybarSuperviseCmd = &cobra.Command{
+	Use:    "supervise [bars...]",
+	Short:  "Runs and restarts polybar bars. Started in the background by 'dot polybar'.",
+	Hidden: true,
+	Args:   cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		s := newSupervisor(_superviseTheme, args)
+		if err := s.run(); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	polybarCmd.AddCommand(polybarSuperviseCmd)
+	polybarSuperviseCmd.Flags().StringVar(&_superviseTheme, "theme", "", "Name of the theme the bars belong to.")
+}
+
+// runtimeDir is where dot keeps files that only make sense for the current login session.
+func runtimeDir() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("dot-%d", os.Getuid()))
+	} else {
+		dir = filepath.Join(dir, "dot")
+	}
+	return dir
+}
+
+func polybarPidFile() string {
+	return filepath.Join(runtimeDir(), "polybar.pid")
+}
+
+// readPolybarState returns an empty state if dot isn't running any bars.
+func readPolybarState() (polybarState, error) {
+	s := polybarState{Bars: map[string]*barState{}}
+	data, err := ioutil.ReadFile(polybarPidFile())
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("failed to parse %s: %s", polybarPidFile(), err)
+	}
+	if s.Bars == nil {
+		s.Bars = map[string]*barState{}
+	}
+	return s, nil
+}
+
+func (s polybarState) write() error {
+	if err := os.MkdirAll(runtimeDir(), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+	// write to a temporary file first so readers never see a partial file
+	tmp := polybarPidFile() + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, polybarPidFile())
+}
+
+// barNames returns the names of the bars in the state, sorted.
+func (s polybarState) barNames() []string {
+	var names []string
+	for name := range s.Bars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, syscall.Signal(0))
+	return err == nil || err == syscall.EPERM
+}
+
+// waitForExit polls until the process is gone or the timeout is reached.
+func waitForExit(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if !processAlive(pid) {
+			return true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return !processAlive(pid)
+}
+
+// stopPolybar stops the supervisor and every bar it started. Bars that were
+// not started by dot are left alone.
+func stopPolybar() error {
+	s, err := readPolybarState()
+	if err != nil {
+		return err
+	}
+	if processAlive(s.Supervisor) {
+		log.Infof("Stopping polybar supervisor (pid %d)", s.Supervisor)
+		syscall.Kill(s.Supervisor, syscall.SIGTERM)
+		if !waitForExit(s.Supervisor, barStopTimeout) {
+			log.Warnf("Supervisor (pid %d) did not exit, killing it", s.Supervisor)
+			syscall.Kill(s.Supervisor, syscall.SIGKILL)
+		}
+	}
+	// The supervisor stops its bars on exit. Anything left over is from a
+	// supervisor that crashed or was killed.
+	for _, name := range s.barNames() {
+		pid := s.Bars[name].Pid
+		if processAlive(pid) {
+			log.Infof("Stopping bar '%s' (pid %d)", name, pid)
+			syscall.Kill(pid, syscall.SIGTERM)
+			if !waitForExit(pid, barStopTimeout) {
+				syscall.Kill(pid, syscall.SIGKILL)
+			}
+		}
+	}
+	err = os.Remove(polybarPidFile())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// startPolybar starts the supervisor in the background and waits until all
+// bars are healthy. The supervisor keeps running after dot exits.
+func startPolybar(env []string, theme string, bars []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	args := []string{"polybar", "supervise", "--theme", theme}
+	if cfgFile != "" {
+		args = append(args, "--config", cfgFile)
+	}
+	args = append(args, bars...)
+	cmd := exec.Command(exe, args...)
+	cmd.Env = env
+	// Put the supervisor in its own session so it isn't killed along with
+	// the terminal (or i3 exec) that started dot.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start polybar supervisor: %s", err)
+	}
+	pid := cmd.Process.Pid
+	// Reap the supervisor if it dies while we are still waiting on it.
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	return waitForBars(pid, bars, exited)
+}
+
+// waitForBars returns once every bar has been up for barHealthyAfter.
+func waitForBars(supervisor int, bars []string, exited <-chan struct{}) error {
+	deadline := time.Now().Add(barStartTimeout)
+	for {
+		select {
+		case <-exited:
+			return fmt.Errorf("polybar supervisor (pid %d) exited unexpectedly", supervisor)
+		case <-time.After(200 * time.Millisecond):
+		}
+		if !processAlive(supervisor) {
+			return fmt.Errorf("polybar supervisor (pid %d) is not running", supervisor)
+		}
+		s, err := readPolybarState()
+		if err != nil {
+			return err
+		}
+		var unhealthy []string
+		for _, bar := range bars {
+			b, ok := s.Bars[bar]
+			if s.Supervisor != supervisor || !ok || !processAlive(b.Pid) || time.Since(b.Started) < barHealthyAfter {
+				unhealthy = append(unhealthy, bar)
+			}
+		}
+		if len(unhealthy) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("bars did not start: %s", strings.Join(unhealthy, ", "))
+		}
+	}
+}
+
+// supervisor runs one polybar process per bar instance and restarts bars that exit.
+type supervisor struct {
+	mu       sync.Mutex
+	state    polybarState
+	bars     []string
+	procs    map[string]*os.Process
+	stopping chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newSupervisor(theme string, bars []string) *supervisor {
+	return &supervisor{
+		state: polybarState{
+			Supervisor: os.Getpid(),
+			Theme:      theme,
+			Bars:       map[string]*barState{},
+		},
+		bars:     bars,
+		procs:    map[string]*os.Process{},
+		stopping: make(chan struct{}),
+	}
+}
+
+func (s *supervisor) run() error {
+	// nobody sees the supervisor's output, it runs in the background
+	if f, err := openRotatingFile(polybarLogFile("supervisor")); err == nil {
+		log.SetOutput(f)
+		defer f.Close()
+	}
+	if err := s.save(); err != nil {
+		return err
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+
+	for _, bar := range s.bars {
+		s.wg.Add(1)
+		go s.supervise(bar)
+	}
+	<-sigs
+	close(s.stopping)
+
+	s.mu.Lock()
+	for _, p := range s.procs {
+		p.Signal(syscall.SIGTERM)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+
+	err := os.Remove(polybarPidFile())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// copyLog writes the output of a bar to its log file, one timestamped line at a time.
+func copyLog(w io.Writer, r *os.File) {
+	defer r.Close()
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fmt.Fprintf(w, "%s %s\n", time.Now().Format(time.RFC3339), scanner.Text())
+	}
+}
+
+// save writes the current state to the pid file.
+func (s *supervisor) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state.write()
+}
+
+func (s *supervisor) supervise(bar string) {
+	defer s.wg.Done()
+	logFile, err := openRotatingFile(polybarLogFile(bar))
+	if err != nil {
+		log.Errorf("Failed to open log file for bar %s: %s", bar, err)
+	} else {
+		defer logFile.Close()
+	}
+	backoff := barMinBackoff
+	restarts := 0
+	for {
+		instance := parseBarInstance(bar)
+		cmd := exec.Command("polybar", "-r", instance.Bar)
+		cmd.Env = os.Environ()
+		if instance.Monitor != "" {
+			cmd.Env = append(cmd.Env, "MONITOR="+instance.Monitor)
+		}
+		var logPipe, logWriter *os.File
+		if logFile != nil {
+			if logPipe, logWriter, err = os.Pipe(); err != nil {
+				log.Errorln(err)
+			} else {
+				cmd.Stdout, cmd.Stderr = logWriter, logWriter
+			}
+		}
+		started := time.Now()
+		err = cmd.Start()
+		if logWriter != nil {
+			// the bar, and any scripts it runs, hold their own copy of the pipe
+			logWriter.Close()
+			go copyLog(logFile, logPipe)
+		}
+		if err != nil {
+			log.Errorf("Starting bar %s failed with %s", bar, err)
+		} else {
+			s.mu.Lock()
+			s.procs[bar] = cmd.Process
+			s.state.Bars[bar] = &barState{Pid: cmd.Process.Pid, Started: started, Restarts: restarts}
+			// run signals the bars under the same lock once stopping is
+			// closed, a bar started after that is stopped here
+			select {
+			case <-s.stopping:
+				cmd.Process.Signal(syscall.SIGTERM)
+			default:
+			}
+			s.mu.Unlock()
+			if err := s.save(); err != nil {
+				log.Errorln(err)
+			}
+			err = cmd.Wait()
+			s.mu.Lock()
+			delete(s.procs, bar)
+			s.mu.Unlock()
+		}
+
+		select {
+		case <-s.stopping:
+			return
+		default:
+		}
+		if time.Since(started) > barHealthyAfter {
+			backoff = barMinBackoff
+		}
+		log.Warnf("Bar %s exited (%v), restarting in %s", bar, err, backoff)
+		select {
+		case <-s.stopping:
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > barMaxBackoff {
+			backoff = barMaxBackoff
+		}
+		restarts++
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var polybarThemeCmd = &cobra.Command{
+	Use:   "theme",
+	Short: "Manage installed polybar themes.",
+	Long: `Themes are directories in polybar.themes_directory. A theme has a bar config and
+optionally a theme.yml that describes it. The bar config is a polybar config named
+'config', or for themes with 'backend: i3bar' in their theme.yml, i3bar blocks in
+'i3bar.conf'. Themes that extend another theme can use its bar config.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+func init() {
+	polybarCmd.AddCommand(polybarThemeCmd)
+}
+
+// initThemes looks up the installed themes.
+func initThemes() {
+	FullThemesPath = Home + "/" + Config.Polybar.ThemesDirectory
+	InstalledPolybarThemes = nil
+	findThemes()
+}
+
+// registeredThemes returns polybar.themes as it is in the config file, so that
+// it can be written back without adding every empty setting of Theme.
+func registeredThemes() []interface{} {
+	themes, _ := viper.Get("polybar.themes").([]interface{})
+	return themes
+}
+
+func registeredThemeName(t interface{}) string {
+	switch m := t.(type) {
+	case map[string]interface{}:
+		name, _ := m["name"].(string)
+		return name
+	case map[interface{}]interface{}:
+		name, _ := m["name"].(string)
+		return name
+	}
+	return ""
+}
+
+// registerTheme adds a theme to polybar.themes if it isn't there yet.
+func registerTheme(name string) error {
+	themes := registeredThemes()
+	for _, t := range themes {
+		if registeredThemeName(t) == name {
+			return nil
+		}
+	}
+	themes = append(themes, map[string]interface{}{"name": name})
+	viper.Set("polybar.themes", themes)
+	return viper.WriteConfig()
+}
+
+// unregisterTheme removes a theme from polybar.themes.
+func unregisterTheme(name string) error {
+	var themes []interface{}
+	found := false
+	for _, t := range registeredThemes() {
+		if registeredThemeName(t) == name {
+			found = true
+			continue
+		}
+		themes = append(themes, t)
+	}
+	if !found {
+		return nil
+	}
+	viper.Set("polybar.themes", themes)
+	return viper.WriteConfig()
+}
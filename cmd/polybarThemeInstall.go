@@ -0,0 +1,358 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	_installName  string
+	_installForce bool
+)
+
+var polybarThemeInstallCmd = &cobra.Command{
+	Use:   "install <path>",
+	Short: "Install a theme from a directory, a .tar.gz or .zip archive, or a local git repository.",
+	Long: `Copies or unpacks the theme into polybar.themes_directory and adds it to polybar.themes.
+
+The theme is checked like 'dot polybar check' does before it is installed. Use --force
+to install a theme with problems, or to replace an installed theme with the same name.
+
+The theme is named after the 'name' in its theme.yml, or after the path if it has none.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		initThemes()
+		name, err := installTheme(args[0], _installName, _installForce)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("Installed theme \"%s\", load it with 'dot polybar -t %s'", name, name)
+	},
+}
+
+func init() {
+	polybarThemeCmd.AddCommand(polybarThemeInstallCmd)
+	polybarThemeInstallCmd.Flags().StringVarP(&_installName, "name", "n", "", "Install the theme under this name.")
+	polybarThemeInstallCmd.Flags().BoolVarP(&_installForce, "force", "f", false, "Install even if the theme has problems or is already installed.")
+}
+
+// installTheme unpacks the theme at src into the themes directory and registers it.
+func installTheme(src, name string, force bool) (string, error) {
+	staging, err := ioutil.TempDir(FullThemesPath, ".install-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(staging)
+
+	unpacked := filepath.Join(staging, "theme")
+	if err := unpackTheme(src, unpacked); err != nil {
+		return "", err
+	}
+	root, err := themeRoot(unpacked)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", src, err)
+	}
+
+	metadata, err := readThemeMetadata(filepath.Join(root, themeMetadataFile))
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		name = metadata.Name
+	}
+	if name == "" {
+		name = themeNameFromPath(src)
+	}
+	if !validThemeName(name) {
+		return "", fmt.Errorf("invalid theme name \"%s\", set one with --name", name)
+	}
+
+	dest := filepath.Join(FullThemesPath, name)
+	if !insideDir(FullThemesPath, dest) {
+		return "", fmt.Errorf("invalid theme name \"%s\", set one with --name", name)
+	}
+	if _, err := os.Stat(dest); err == nil && !force {
+		return "", fmt.Errorf("theme \"%s\" is already installed in %s, use --force to replace it", name, dest)
+	}
+
+	theme := mergeTheme(metadata, findTheme(name))
+	theme.Name = name
+	if theme.Extends != "" {
+		parent, err := resolveTheme(theme.Extends, nil)
+		if err != nil {
+			return "", fmt.Errorf("theme \"%s\" extends \"%s\": %s", name, theme.Extends, err)
+		}
+		theme = mergeTheme(parent, theme)
+		theme.Name = name
+	}
+	b, err := themeBackend(theme)
+	if err != nil {
+		return "", err
+	}
+	config := filepath.Join(root, b.ConfigFile())
+	if _, err := os.Stat(config); os.IsNotExist(err) && theme.Extends != "" {
+		config = themeConfigPath(theme.Extends)
+	}
+	problems := checkTheme(theme, config)
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 && !force {
+		return "", fmt.Errorf("theme \"%s\" has %d problem(s), use --force to install it anyway", name, len(problems))
+	}
+
+	if err := os.RemoveAll(dest); err != nil {
+		return "", err
+	}
+	if err := os.Rename(root, dest); err != nil {
+		return "", err
+	}
+	return name, registerTheme(name)
+}
+
+// validThemeName reports whether a theme can be installed under name. The
+// name can come from an archive, it must not point outside the themes
+// directory.
+func validThemeName(name string) bool {
+	switch name {
+	case "", ".", "..", "global":
+		return false
+	}
+	return filepath.Base(name) == name && !strings.ContainsAny(name, `/\:`)
+}
+
+// insideDir reports whether path is in dir, and not dir itself.
+func insideDir(dir, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// themeNameFromPath turns e.g. ~/Downloads/nord.tar.gz into nord.
+func themeNameFromPath(src string) string {
+	name := filepath.Base(filepath.Clean(src))
+	for _, ext := range []string{".tar.gz", ".tgz", ".zip", ".git"} {
+		name = strings.TrimSuffix(name, ext)
+	}
+	return name
+}
+
+// themeRoot finds the theme in an unpacked directory. Archives often contain a
+// single directory with the theme in it.
+func themeRoot(dir string) (string, error) {
+	for {
+		if isThemeDir(dir) {
+			return dir, nil
+		}
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return "", err
+		}
+		if len(files) != 1 || !files[0].IsDir() {
+			return "", fmt.Errorf("no bar config or %s found", themeMetadataFile)
+		}
+		dir = filepath.Join(dir, files[0].Name())
+	}
+}
+
+// isThemeDir reports whether dir has a theme.yml or the config of a bar backend.
+func isThemeDir(dir string) bool {
+	files := []string{themeMetadataFile}
+	for _, name := range barBackendNames() {
+		files = append(files, barBackends[name].ConfigFile())
+	}
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(dir, f)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// unpackTheme copies, unpacks or clones src into dest.
+func unpackTheme(src, dest string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.IsDir() && isGitRepo(src):
+		return cloneTheme(src, dest)
+	case info.IsDir():
+		return copyDir(src, dest)
+	case strings.HasSuffix(src, ".tar.gz") || strings.HasSuffix(src, ".tgz"):
+		return untar(src, dest)
+	case strings.HasSuffix(src, ".zip"):
+		return unzip(src, dest)
+	}
+	return fmt.Errorf("don't know how to install %s, expected a directory, .tar.gz, .zip or git repository", src)
+}
+
+// isGitRepo reports whether dir is a git working tree or a bare repository.
+func isGitRepo(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		return true
+	}
+	_, errHead := os.Stat(filepath.Join(dir, "HEAD"))
+	_, errObjects := os.Stat(filepath.Join(dir, "objects"))
+	return errHead == nil && errObjects == nil
+}
+
+// cloneTheme checks out the committed state of a local git repository,
+// leaving out uncommitted files and the repository itself.
+func cloneTheme(src, dest string) error {
+	out, err := exec.Command("git", "clone", "--quiet", "--depth", "1", "file://"+absPath(src), dest).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git clone %s failed: %s\n%s", src, err, out)
+	}
+	return os.RemoveAll(filepath.Join(dest, ".git"))
+}
+
+func absPath(p string) string {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	return abs
+}
+
+// copyDir copies a directory tree, keeping file modes and symlinks.
+func copyDir(src, dest string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return writeFile(target, f, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+func writeFile(path string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// archivePath returns where an archive entry is unpacked to, refusing entries
+// that would end up outside of dest.
+func archivePath(dest, name string) (string, error) {
+	target := filepath.Join(dest, name)
+	if target != dest && !strings.HasPrefix(target, filepath.Clean(dest)+string(os.PathSeparator)) {
+		return "", fmt.Errorf("archive entry %s is outside of the theme", name)
+	}
+	return target, nil
+}
+
+func untar(src, dest string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%s: %s", src, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %s", src, err)
+		}
+		target, err := archivePath(dest, h.Name)
+		if err != nil {
+			return err
+		}
+		switch h.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeReg, tar.TypeRegA:
+			err = writeFile(target, tr, os.FileMode(h.Mode).Perm())
+		default:
+			log.Warnf("Skipping %s in %s, only files and directories are installed", h.Name, src)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func unzip(src, dest string) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return fmt.Errorf("%s: %s", src, err)
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		target, err := archivePath(dest, f.Name)
+		if err != nil {
+			return err
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			log.Warnf("Skipping %s in %s, only files and directories are installed", f.Name, src)
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeFile(target, rc, f.Mode().Perm())
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
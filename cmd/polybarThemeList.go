@@ -0,0 +1,97 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var _details bool
+
+var polybarThemeListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List installed themes.",
+	Long: `Lists the themes in polybar.themes_directory. With --details, also shows each theme's
+description and author, and whether the fonts and scripts it needs are installed.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		initThemes()
+		if !_details {
+			listThemes()
+			return
+		}
+		for _, name := range InstalledPolybarThemes {
+			printThemeDetails(name)
+		}
+	},
+}
+
+func init() {
+	polybarThemeCmd.AddCommand(polybarThemeListCmd)
+	polybarThemeListCmd.Flags().BoolVarP(&_details, "details", "d", false, "Show metadata and missing fonts and scripts.")
+}
+
+func printThemeDetails(name string) {
+	current := ""
+	if name == Config.Polybar.Theme {
+		current = " (current)"
+	}
+	fmt.Printf("%s%s\n", name, current)
+
+	theme, err := loadTheme(name)
+	if err != nil {
+		fmt.Printf("  error: %s\n\n", err)
+		return
+	}
+	if theme.Description != "" {
+		fmt.Printf("  description: %s\n", theme.Description)
+	}
+	if theme.Author != "" {
+		fmt.Printf("  author: %s\n", theme.Author)
+	}
+	if theme.Extends != "" {
+		fmt.Printf("  extends: %s\n", theme.Extends)
+	}
+	if hasPalette(theme) {
+		if p, err := resolvePalette(theme); err == nil {
+			fmt.Printf("  palette: %s\n", p.Name)
+		}
+	}
+	if len(theme.Variants) > 0 {
+		var variants []string
+		for v := range theme.Variants {
+			variants = append(variants, v)
+		}
+		sort.Strings(variants)
+		fmt.Printf("  variants: %s\n", strings.Join(variants, ", "))
+	}
+
+	missing := map[string][]string{}
+	for _, p := range checkTheme(theme, themeConfigPath(name)) {
+		missing[p.Kind] = append(missing[p.Kind], p.String())
+	}
+	for _, kind := range []string{problemFont, problemScript} {
+		status := "ok"
+		if _, err := exec.LookPath("fc-match"); kind == problemFont && err != nil {
+			status = "not checked, fc-match was not found"
+		}
+		if len(missing[kind]) > 0 {
+			status = "missing\n    " + strings.Join(missing[kind], "\n    ")
+		}
+		fmt.Printf("  %ss: %s\n", kind, status)
+	}
+	// anything else stops the theme from loading at all
+	var other []string
+	for _, kind := range []string{problemConfig, problemBar, problemModule, problemPalette, problemI3} {
+		other = append(other, missing[kind]...)
+	}
+	if len(other) > 0 {
+		fmt.Printf("  problems:\n    %s\n", strings.Join(other, "\n    "))
+	}
+	fmt.Println()
+}
@@ -0,0 +1,354 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/patrick-motard/dot/lib"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var _from string
+
+var polybarThemeNewCmd = &cobra.Command{
+	Use:   "new <name>",
+	Short: "Create a new theme.",
+	Long: `Creates a theme directory in polybar.themes_directory with a polybar config and a theme.yml.
+
+The generated config includes the polybar configs of the 'global' theme and has a bar for the
+primary monitor and one for every other monitor. dot tells polybar which monitor a
+bar belongs on through $MONITOR.
+
+With --from, the new theme is a copy of an installed theme. The [bar/...] sections of
+the copy are renamed after the new theme, so both themes can be told apart.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		initThemes()
+		name := args[0]
+		var err error
+		if _from != "" {
+			err = cloneThemeAs(_from, name)
+		} else {
+			err = newTheme(name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := registerTheme(name); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("Created theme \"%s\" in %s, load it with 'dot polybar -t %s'", name, filepath.Join(FullThemesPath, name), name)
+	},
+}
+
+func init() {
+	polybarThemeCmd.AddCommand(polybarThemeNewCmd)
+	polybarThemeNewCmd.Flags().StringVarP(&_from, "from", "f", "", "Copy an installed theme instead of generating one.")
+}
+
+var newThemeConfig = template.Must(template.New("config").Parse(`; Polybar config of the {{ .Name }} theme, generated by 'dot polybar theme new'.
+; dot sets $MONITOR to the monitor each bar is launched on, see roles in theme.yml.
+{{ range .Includes }}
+include-file = {{ . }}
+{{- else }}
+; there was no 'global' theme to include when this theme was created
+{{- end }}
+
+[bar/{{ .Name }}-main]
+monitor = ${env:MONITOR:}
+width = 100%
+height = 30
+offset-y = 0
+fixed-center = true
+background = #222
+foreground = #dfdfdf
+padding-left = 1
+padding-right = 1
+module-margin = 1
+font-0 = monospace:size=10;2
+modules-left = {{ .Name }}-date
+modules-right =
+
+[bar/{{ .Name }}-side]
+inherit = bar/{{ .Name }}-main
+modules-left = {{ .Name }}-date
+
+; named after the theme, so it doesn't clash with modules of the included configs
+[module/{{ .Name }}-date]
+type = internal/date
+interval = 5
+date = %Y-%m-%d
+time = %H:%M
+label = %date% %time%
+`))
+
+var newThemeMetadata = template.Must(template.New("theme.yml").Parse(`description: {{ .Name }} theme
+author: {{ .Author }}
+# bars to launch per monitor role: primary, secondary, all or a display alias
+roles:
+  primary:
+  - {{ .Name }}-main
+  secondary:
+  - {{ .Name }}-side
+# i3 gaps while the theme is loaded
+gaps:
+  top: 30
+# fonts the theme needs
+fonts: []
+`))
+
+// newTheme generates a theme.
+func newTheme(name string) error {
+	dir, err := newThemeDir(name)
+	if err != nil {
+		return err
+	}
+	var includes []string
+	global := filepath.Join(FullThemesPath, "global")
+	if files, err := ioutil.ReadDir(global); err == nil {
+		for _, f := range files {
+			// global can also hold scripts and images, only polybar configs
+			// are included
+			if !f.IsDir() && isPolybarConfig(filepath.Join(global, f.Name())) {
+				includes = append(includes, filepath.Join("..", "global", f.Name()))
+			}
+		}
+	}
+	data := struct {
+		Name     string
+		Author   string
+		Includes []string
+	}{name, os.Getenv("USER"), includes}
+
+	for file, t := range map[string]*template.Template{"config": newThemeConfig, themeMetadataFile: newThemeMetadata} {
+		f, err := os.Create(filepath.Join(dir, file))
+		if err != nil {
+			return err
+		}
+		err = t.Execute(f, data)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isPolybarConfig reports whether polybar can read a file, and it has sections.
+func isPolybarConfig(path string) bool {
+	c, err := lib.ParsePolybarConfig(path)
+	return err == nil && len(c.SectionNames()) > 0
+}
+
+// newThemeDir checks the name of a new theme and creates its directory.
+func newThemeDir(name string) (string, error) {
+	// @ separates bars from monitors, e.g. main@DP-4
+	if !validThemeName(name) || strings.Contains(name, "@") {
+		return "", fmt.Errorf("invalid theme name \"%s\"", name)
+	}
+	dir := filepath.Join(FullThemesPath, name)
+	if _, err := os.Stat(dir); err == nil {
+		return "", fmt.Errorf("theme \"%s\" already exists in %s", name, dir)
+	}
+	return dir, os.MkdirAll(dir, 0755)
+}
+
+// cloneThemeAs copies an installed theme and renames its bars.
+func cloneThemeAs(from, name string) error {
+	_theme = from
+	if !validateTheme() {
+		return fmt.Errorf("theme \"%s\" is not installed", from)
+	}
+	theme, err := loadTheme(from)
+	if err != nil {
+		return err
+	}
+	b, err := themeBackend(theme)
+	if err != nil {
+		return err
+	}
+	// only polybar bars are renamed, i3bar bars are named after their theme
+	// when they are loaded
+	var c *lib.PolybarConfig
+	if _, ok := b.(polybarBackend); ok {
+		if c, err = loadPolybarConfig(themeConfigPath(from)); err != nil {
+			return err
+		}
+	}
+
+	dir, err := newThemeDir(name)
+	if err != nil {
+		return err
+	}
+	if err := copyDir(filepath.Join(FullThemesPath, from), dir); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+
+	renames := map[string]string{}
+	if c != nil {
+		// only the theme's own bars are renamed. Bars of the theme it extends
+		// or of the global theme keep their names, their files aren't copied.
+		src := filepath.Join(FullThemesPath, from)
+		for _, bar := range c.Bars() {
+			if s, ok := c.Section("bar/" + bar); ok && insideDir(src, s.File) {
+				renames[bar] = renameBar(bar, from, name)
+			}
+		}
+		// rewrite the bars in every config file that belongs to the theme
+		for _, file := range c.Files() {
+			if !insideDir(src, file) {
+				continue
+			}
+			rel, _ := filepath.Rel(src, file)
+			if err := renameBarsInFile(filepath.Join(dir, rel), renames); err != nil {
+				return err
+			}
+		}
+	}
+
+	// theme.yml was copied with the rest of the theme, only the bars in it
+	// are renamed
+	if err := renameBarsInMetadata(filepath.Join(dir, themeMetadataFile), renames); err != nil {
+		return err
+	}
+	// the theme's entry in polybar.themes, e.g. its gaps or palette, is
+	// copied to the new theme with its bars renamed
+	for _, t := range registeredThemes() {
+		if registeredThemeName(t) != from {
+			continue
+		}
+		entry, ok := copyThemeEntry(t, renames, "").(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		entry["name"] = name
+		viper.Set("polybar.themes", append(registeredThemes(), entry))
+		return viper.WriteConfig()
+	}
+	return nil
+}
+
+// copyThemeEntry copies a value of an entry in polybar.themes, key is the key
+// it is set under. Bars in bars and roles, also those of variants, are renamed.
+func copyThemeEntry(v interface{}, renames map[string]string, key string) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, val := range v {
+			m[fmt.Sprint(k)] = val
+		}
+		return copyThemeEntry(m, renames, key)
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for k, val := range v {
+			// roles map roles to lists of bars
+			childKey := k
+			if key == "roles" {
+				childKey = "bars"
+			}
+			m[k] = copyThemeEntry(val, renames, childKey)
+		}
+		return m
+	case []interface{}:
+		var list []interface{}
+		for _, item := range v {
+			if bar, ok := item.(string); ok && key == "bars" {
+				if r, ok := renames[bar]; ok {
+					item = r
+				}
+			}
+			list = append(list, copyThemeEntry(item, renames, ""))
+		}
+		return list
+	}
+	return v
+}
+
+// renameBar names a bar after the new theme.
+// example: nord.main, nord -> solar -> solar.main
+// example: main, nord -> solar -> solar-main
+func renameBar(bar, from, name string) string {
+	if strings.Contains(bar, from) {
+		return strings.Replace(bar, from, name, -1)
+	}
+	return name + "-" + bar
+}
+
+// renameBarsInFile rewrites [bar/...] headers and references to bars, like
+// 'inherit = bar/...' and ${bar/....key}.
+func renameBarsInFile(path string, renames map[string]string) error {
+	if len(renames) == 0 {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for old := range renames {
+		names = append(names, regexp.QuoteMeta(old))
+	}
+	// longest names first, so that bar/main doesn't match bar/main.top
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	re := regexp.MustCompile(`(?m)\bbar/(` + strings.Join(names, "|") + `)(\]|\.|\s|$)`)
+	out := re.ReplaceAllStringFunc(string(data), func(m string) string {
+		sub := re.FindStringSubmatch(m)
+		return "bar/" + renames[sub[1]] + sub[2]
+	})
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(out), info.Mode())
+}
+
+var (
+	yamlListItemRe = regexp.MustCompile(`^(\s*-\s+)(["']?)([^"'#\s]+)(["']?\s*(#.*)?)$`)
+	yamlFlowListRe = regexp.MustCompile(`\[[^\[\]]*\]`)
+)
+
+// renameBarsInMetadata renames bars in the lists of a theme.yml, like bars and
+// the roles, and keeps everything else of the file as it is.
+func renameBarsInMetadata(path string, renames map[string]string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) || len(renames) == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if m := yamlListItemRe.FindStringSubmatch(line); m != nil {
+			if r, ok := renames[m[3]]; ok {
+				lines[i] = m[1] + m[2] + r + m[4]
+			}
+			continue
+		}
+		lines[i] = yamlFlowListRe.ReplaceAllStringFunc(line, func(list string) string {
+			items := strings.Split(list[1:len(list)-1], ",")
+			for j, item := range items {
+				bar := strings.Trim(strings.TrimSpace(item), `"'`)
+				if r, ok := renames[bar]; ok && bar != "" {
+					items[j] = strings.Replace(item, bar, r, 1)
+				}
+			}
+			return "[" + strings.Join(items, ",") + "]"
+		})
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), info.Mode())
+}
@@ -0,0 +1,49 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var polybarThemeRemoveCmd = &cobra.Command{
+	Use:   "remove <theme>",
+	Short: "Remove an installed theme.",
+	Long:  `Deletes the theme's directory from polybar.themes_directory and removes it from polybar.themes.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		initThemes()
+		if err := removeTheme(args[0]); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("Removed theme \"%s\"", args[0])
+	},
+}
+
+func init() {
+	polybarThemeCmd.AddCommand(polybarThemeRemoveCmd)
+}
+
+func removeTheme(name string) error {
+	_theme = name
+	if strings.Contains(name, ":") || !validateTheme() {
+		return fmt.Errorf("theme \"%s\" is not installed", name)
+	}
+	if current, _ := splitThemeName(Config.Polybar.Theme); name == current {
+		return fmt.Errorf("theme \"%s\" is the current theme, load another theme before removing it", name)
+	}
+	for _, other := range InstalledPolybarThemes {
+		if chain := themeChain(other); other != name && len(chain) > 1 && chain[1] == name {
+			return fmt.Errorf("theme \"%s\" extends \"%s\", remove it first", other, name)
+		}
+	}
+	if err := os.RemoveAll(filepath.Join(FullThemesPath, name)); err != nil {
+		return err
+	}
+	return unregisterTheme(name)
+}
@@ -0,0 +1,106 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// How long to wait for more changes before reloading. Editors write files in
+// several steps when saving.
+const watchDebounce = 500 * time.Millisecond
+
+// watchTheme reloads the bars whenever a file of the current theme or the
+// global theme changes. It runs until dot is stopped, or another theme is
+// loaded.
+func watchTheme() error {
+	watched := Config.Polybar.Theme
+	// the theme's config may be in a theme it extends
+	var dirs []string
+	for _, name := range themeChain(watched) {
+		dirs = append(dirs, filepath.Join(FullThemesPath, name))
+	}
+	dirs = append(dirs, filepath.Join(FullThemesPath, "global"))
+	w, err := newFileWatcher(dirs...)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	log.Infof("Watching %s for changes", dirs)
+
+	for changed := range debounce(w.Events, watchDebounce) {
+		log.Infof("Changed: %s", changed)
+		lock, err := lockPolybar(ifRunningWait)
+		if err != nil {
+			log.Errorln(err)
+			continue
+		}
+		// another dot command, like the theme schedule, may have loaded a
+		// different theme in the meantime
+		if err := rereadConfig(); err != nil {
+			lock.Unlock()
+			return err
+		}
+		if Config.Polybar.Theme != watched {
+			lock.Unlock()
+			log.Infof("Theme \"%s\" was replaced by \"%s\", stopping to watch it", watched, Config.Polybar.Theme)
+			return nil
+		}
+		if err := reloadTheme(changed); err != nil {
+			log.Errorln(err)
+		}
+		lock.Unlock()
+	}
+	return nil
+}
+
+// reloadTheme checks the current theme and reloads its bars if it has no problems.
+func reloadTheme(changed []string) error {
+	theme, err := loadTheme(Config.Polybar.Theme)
+	if err != nil {
+		return err
+	}
+	if problems := checkTheme(theme, FullThemePath); len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		return fmt.Errorf("theme \"%s\" has %d problem(s), keeping the running bars", theme.Name, len(problems))
+	}
+	// theme.yml decides which bars run, so the bars have to be launched again
+	for _, f := range changed {
+		if filepath.Base(f) == themeMetadataFile {
+			return loadPolybar()
+		}
+	}
+	return reloadBars()
+}
+
+// reloadBars makes the running bars read their config again, see the Reload
+// of the theme's backend.
+func reloadBars() error {
+	theme, err := loadTheme(Config.Polybar.Theme)
+	if err != nil {
+		return err
+	}
+	b, err := themeBackend(theme)
+	if err != nil {
+		return err
+	}
+	instances, err := b.Running()
+	if err != nil {
+		return err
+	}
+	if len(instances) == 0 {
+		return loadPolybar()
+	}
+	// the bars may have changed size
+	env := polybarMonitorEnv(&displays{})
+	gaps, err := b.Gaps(FullThemePath, instances, env)
+	if err != nil {
+		log.Errorf("Failed to work out the room the bars need: %s", err)
+	}
+	adjustI3Gaps(theme.Gaps, gaps)
+	return b.Reload(FullThemePath, env)
+}
@@ -0,0 +1,144 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+	"github.com/patrick-motard/dot/lib"
+	"go.i3wm.org/i3"
+)
+
+// barWindow is where a bar's window is on the screen.
+type barWindow struct {
+	X, Y          int
+	Width, Height int
+}
+
+// waitForBarWindows waits until every bar instance has mapped a window on the
+// monitor it belongs on. A bar whose process is up can still fail to show,
+// e.g. when its monitor setting names an output that isn't connected.
+func waitForBarWindows(c *lib.PolybarConfig, instances []barInstance, env map[string]string) error {
+	X, err := xgb.NewConn()
+	if err != nil {
+		log.Warnf("Can't connect to X to check the bars' windows: %s", err)
+		return nil
+	}
+	defer X.Close()
+	pidAtom, err := xproto.InternAtom(X, true, uint16(len("_NET_WM_PID")), "_NET_WM_PID").Reply()
+	if err != nil {
+		return err
+	}
+
+	outputs, err := i3.GetOutputs()
+	if err != nil {
+		return err
+	}
+	rects := map[string]i3.Rect{}
+	primary := ""
+	for _, o := range outputs {
+		if !o.Active {
+			continue
+		}
+		rects[o.Name] = o.Rect
+		if o.Primary || primary == "" {
+			primary = o.Name
+		}
+	}
+
+	deadline := time.Now().Add(barStartTimeout)
+	for {
+		s, err := readPolybarState()
+		if err != nil {
+			return err
+		}
+		windows := map[int]barWindow{}
+		root := xproto.Setup(X).DefaultScreen(X).Root
+		if err := findBarWindows(X, root, root, pidAtom.Atom, windows); err != nil {
+			return err
+		}
+
+		var missing []string
+		for _, i := range instances {
+			b, ok := s.Bars[i.String()]
+			if !ok {
+				missing = append(missing, fmt.Sprintf("%s (not running)", i))
+				continue
+			}
+			w, ok := windows[b.Pid]
+			if !ok {
+				missing = append(missing, fmt.Sprintf("%s (no window)", i))
+				continue
+			}
+			monitor := barMonitor(c, i, env, primary)
+			if rect, ok := rects[monitor]; ok && !w.on(rect) {
+				missing = append(missing, fmt.Sprintf("%s (not on %s)", i, monitor))
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			sort.Strings(missing)
+			return fmt.Errorf("bars did not show up: %s", strings.Join(missing, ", "))
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// findBarWindows collects the viewable windows below win by the pid that owns
+// them. i3 reparents docked bars, so the whole tree is searched.
+func findBarWindows(X *xgb.Conn, root, win xproto.Window, pidAtom xproto.Atom, windows map[int]barWindow) error {
+	tree, err := xproto.QueryTree(X, win).Reply()
+	if err != nil {
+		return err
+	}
+	for _, child := range tree.Children {
+		attrs, err := xproto.GetWindowAttributes(X, child).Reply()
+		if err != nil || attrs.MapState != xproto.MapStateViewable {
+			continue
+		}
+		prop, err := xproto.GetProperty(X, false, child, pidAtom, xproto.AtomCardinal, 0, 1).Reply()
+		if err == nil && prop.ValueLen == 1 {
+			geometry, err := xproto.GetGeometry(X, xproto.Drawable(child)).Reply()
+			if err != nil {
+				continue
+			}
+			pos, err := xproto.TranslateCoordinates(X, child, root, 0, 0).Reply()
+			if err != nil {
+				continue
+			}
+			windows[int(xgb.Get32(prop.Value))] = barWindow{int(pos.DstX), int(pos.DstY), int(geometry.Width), int(geometry.Height)}
+			continue
+		}
+		if err := findBarWindows(X, root, child, pidAtom, windows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// on reports whether the middle of the window is on the output.
+func (w barWindow) on(r i3.Rect) bool {
+	x, y := int64(w.X+w.Width/2), int64(w.Y+w.Height/2)
+	return x >= r.X && x < r.X+r.Width && y >= r.Y && y < r.Y+r.Height
+}
+
+// barMonitor returns the output a bar instance goes on. Bars without a
+// monitor go on the primary output.
+func barMonitor(c *lib.PolybarConfig, i barInstance, env map[string]string, primary string) string {
+	if i.Monitor != "" {
+		return i.Monitor
+	}
+	defer setBarEnv(c, env, "")()
+	monitor, _ := c.GetDefault("bar/"+i.Bar, "monitor", "")
+	if monitor == "" {
+		return primary
+	}
+	return monitor
+}
@@ -33,6 +33,7 @@ If you pass in a port, it sets config file to the port.
 		}
 		viper.Set("sound.port", args[0])
 		viper.WriteConfig()
+		notifyPolybar("sound")
 
 		// fmt.Println(viper.Get("sound.port"))
 		// settings.Sound.Port = args[0]
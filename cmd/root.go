@@ -20,10 +20,106 @@ var log = logrus.New()
 var cfgFile string
 var settings lib.Settings
 
+// Theme is a polybar theme. Themes are described by a theme.yml in the theme's
+// directory and by an entry in polybar.themes, which takes precedence.
 type Theme struct {
-	Name string
-	Bars []string
+	Name        string
+	Description string
+	Author      string
+	Bars        []string
+	// Roles maps a monitor role (primary, secondary, all, or a display alias)
+	// to the bars that are launched on every monitor with that role.
+	Roles map[string][]string
+	// Gaps are the i3 gaps used while the theme is loaded.
 	Gaps I3Gaps
+	// Fonts the theme needs, e.g. "Iosevka Nerd Font".
+	Fonts []string
+	// I3 is how i3 draws windows while the theme is loaded.
+	I3 I3Appearance
+	// Palette holds the theme's colors. It can name a palette from palettes and
+	// override some of its colors.
+	Palette Palette
+	// Wallpapers are the theme's wallpapers by output, see Wallpaper.
+	Wallpapers map[string]Wallpaper
+	// Backend is the program that runs the bars, "polybar" (the default) or
+	// "i3bar".
+	Backend string
+	// Extends names a theme this theme inherits its settings from. Settings
+	// the theme sets itself override the inherited ones.
+	Extends string
+	// Variants override settings of the theme, e.g. the bars or gaps of a
+	// display profile. A variant is loaded as "theme:variant".
+	Variants map[string]Theme
+}
+
+// I3Appearance is how i3 draws windows. Settings a theme doesn't set are left
+// to the i3 config.
+type I3Appearance struct {
+	// Border is the border style of windows: normal, pixel or none.
+	Border      string
+	BorderWidth int `mapstructure:"border_width"`
+	// Font is an i3 font, e.g. "pango:Iosevka 10".
+	Font string
+	// SmartGaps is on, off or inverse_outer. SmartBorders is on, off or no_gaps.
+	SmartGaps    string `mapstructure:"smart_gaps"`
+	SmartBorders string `mapstructure:"smart_borders"`
+	// Colors are the client.* colors by class, e.g. focused: "#285577 #285577
+	// #ffffff". Colors can be named after the colors of the theme's palette,
+	// e.g. "accent accent background".
+	Colors map[string]string
+	// WorkspaceGaps are the gaps of single workspaces, by workspace name.
+	WorkspaceGaps map[string]I3Gaps `mapstructure:"workspace_gaps"`
+}
+
+// Palette is a named set of base colors. dot renders the palette of the loaded
+// theme for polybar, i3, rofi, Xresources and terminals. Colors are #rrggbb.
+type Palette struct {
+	Name       string
+	Background string
+	Foreground string
+	// Accent defaults to the foreground, Urgent to the accent.
+	Accent string
+	Urgent string
+	// Colors are the 16 terminal colors, color0 to color15.
+	Colors []string
+}
+
+// Wallpaper is an image, or a directory of images that dot rotates through,
+// and how it is drawn on an output: fill (the default), fit, center or tile.
+// Wallpapers are set by output name, display alias, "primary" or "all".
+type Wallpaper struct {
+	Path string
+	Mode string
+	// Output is only used in wallpaper.outputs, which is a list so outputs
+	// can be removed from it.
+	Output string
+}
+
+// ThemeSchedule picks the theme by the time of day, see 'dot theme schedule'.
+type ThemeSchedule struct {
+	// Latitude and Longitude are where sunrise and sunset are computed for,
+	// in degrees. North and east are positive.
+	Latitude  float64
+	Longitude float64
+	// Times are the themes by time of day. The first one whose range holds
+	// the current time is used.
+	Times []ScheduledTheme
+}
+
+// ScheduledTheme is a theme, and optionally a palette, used from From until To.
+// Times are "HH:MM", "sunrise" or "sunset", which can be moved by a duration,
+// e.g. "sunset-30m". A range that ends before it starts goes past midnight.
+type ScheduledTheme struct {
+	From    string
+	To      string
+	Theme   string
+	Palette string
+}
+
+// PolybarHook is a hook of a polybar custom/ipc module, e.g. hook 1 of module "alsa".
+type PolybarHook struct {
+	Module string
+	Hook   int
 }
 
 type I3Gaps struct {
@@ -31,6 +127,9 @@ type I3Gaps struct {
 	Bottom string
 	Left   string
 	Right  string
+	Inner  string
+	// Outer is only used for workspace gaps.
+	Outer string
 }
 type I3wm struct {
 	DefaultGaps  I3Gaps `mapstructure:"default_gaps"`
@@ -41,15 +140,34 @@ type config struct {
 	Displays struct {
 		Current  string
 		Location string
+		// Aliases name outputs, e.g. laptop: eDP-1
+		Aliases map[string]string
+		// Wallpapers are the wallpapers of each display profile.
+		Wallpapers map[string]map[string]Wallpaper
 	}
 	Sound struct {
 		Port string
 	}
-	I3wm    I3wm `mapstructure:"i3_wm"`
+	I3wm I3wm `mapstructure:"i3_wm"`
+	// Palettes can be used by themes by name.
+	Palettes  []Palette
+	Wallpaper struct {
+		// Outputs are the wallpapers set with 'dot wallpaper set'. They win
+		// over the wallpapers of the display profile and the theme.
+		Outputs []Wallpaper
+	}
 	Polybar struct {
-		Theme           string
+		Theme string
+		// Palette replaces the palette of the loaded theme, see 'dot polybar
+		// --palette'.
+		Palette         string
 		ThemesDirectory string `mapstructure:"themes_directory"`
 		Themes          []Theme
+		// Hooks are sent to the bars when dot changes state. The keys are
+		// events: sound, displays and theme.
+		Hooks map[string][]PolybarHook
+		// Schedule switches themes by the time of day.
+		Schedule ThemeSchedule
 	}
 }
 
@@ -112,6 +230,21 @@ func initConfig() {
 	}
 }
 
+// rereadConfig reads the config file again, for commands that keep running.
+// The settings are decoded into a new config, decoding into Config would keep
+// list entries and fields that were removed from the file.
+func rereadConfig() error {
+	if err := viper.ReadInConfig(); err != nil {
+		return err
+	}
+	var c config
+	if err := viper.Unmarshal(&c); err != nil {
+		return err
+	}
+	Config = c
+	return nil
+}
+
 // example of setting a value and writing config:
 // var newKeypair = make(map[string]string)
 // newKeypair["test"] = "val"
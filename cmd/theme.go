@@ -0,0 +1,21 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var themeCmd = &cobra.Command{
+	Use:   "theme",
+	Short: "Manage the look of the desktop.",
+	Long: `Manages what themes are made of beyond their bars, like palettes. Bar themes are
+loaded with 'dot polybar'.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(themeCmd)
+}
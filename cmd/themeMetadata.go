@@ -0,0 +1,203 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// themeMetadataFile is the name of the file in a theme's directory that
+// describes the theme. It takes the same settings as an entry in polybar.themes.
+const themeMetadataFile = "theme.yml"
+
+// themeMetadataPath returns the path to the theme.yml of an installed theme.
+func themeMetadataPath(name string) string {
+	return filepath.Join(FullThemesPath, name, themeMetadataFile)
+}
+
+// readThemeMetadata reads a theme.yml. A missing file is not an error, themes
+// can still be described in polybar.themes only.
+func readThemeMetadata(path string) (Theme, error) {
+	var t Theme
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return t, nil
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return t, fmt.Errorf("failed to read %s: %s", path, err)
+	}
+	if err := v.Unmarshal(&t); err != nil {
+		return t, fmt.Errorf("failed to decode %s: %s", path, err)
+	}
+	return t, nil
+}
+
+// splitThemeName splits a theme name into the theme and its variant.
+// example: nord:laptop -> nord, laptop
+func splitThemeName(name string) (string, string) {
+	if i := strings.Index(name, ":"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return name, ""
+}
+
+// loadTheme returns the theme with the given name. Settings from the theme's
+// theme.yml are overridden by the theme's entry in polybar.themes, if it has one.
+// Settings of the theme it extends are inherited. A variant is applied if the
+// name asks for one, e.g. nord:laptop, or if the theme has a variant named after
+// the current display profile, see displayProfile. The loaded theme uses polybar.palette instead of
+// its own palette if it is set.
+func loadTheme(name string) (Theme, error) {
+	base, variant := splitThemeName(name)
+	t, err := resolveTheme(base, nil)
+	if err != nil {
+		return Theme{Name: name}, err
+	}
+	if variant == "" && Config.Displays.Current != "" {
+		profile := displayProfile(Config.Displays.Current)
+		if _, ok := t.Variants[profile]; ok {
+			variant = profile
+			log.Infof("Using variant \"%s\" of theme \"%s\" for display profile \"%s\"", variant, base, Config.Displays.Current)
+		}
+	}
+	if variant != "" {
+		v, ok := t.Variants[variant]
+		if !ok {
+			return Theme{Name: name}, fmt.Errorf("theme \"%s\" has no variant \"%s\"", base, variant)
+		}
+		t = mergeTheme(t, v)
+	}
+	if name == Config.Polybar.Theme && Config.Polybar.Palette != "" {
+		t.Palette = Palette{Name: Config.Polybar.Palette}
+	}
+	t.Name = name
+	return t, nil
+}
+
+// resolveTheme reads a theme and the themes it extends. chain holds the themes
+// that extend it, to catch themes that extend each other.
+func resolveTheme(name string, chain []string) (Theme, error) {
+	for _, n := range chain {
+		if n == name {
+			return Theme{}, fmt.Errorf("themes extend each other: %s -> %s", strings.Join(chain, " -> "), name)
+		}
+	}
+	t, err := readThemeMetadata(themeMetadataPath(name))
+	if err != nil {
+		return t, err
+	}
+	t = mergeTheme(t, findTheme(name))
+	t.Name = name
+	if t.Extends == "" {
+		return t, nil
+	}
+	if !isThemeDir(filepath.Join(FullThemesPath, t.Extends)) {
+		return t, fmt.Errorf("theme \"%s\" extends \"%s\", which is not installed", name, t.Extends)
+	}
+	parent, err := resolveTheme(t.Extends, append(chain, name))
+	if err != nil {
+		return t, err
+	}
+	t = mergeTheme(parent, t)
+	t.Name = name
+	return t, nil
+}
+
+// themeChain returns the theme and the themes it extends, closest first.
+// Broken chains are cut short, loadTheme reports them.
+func themeChain(name string) []string {
+	name, _ = splitThemeName(name)
+	var chain []string
+	for name != "" {
+		for _, n := range chain {
+			if n == name {
+				return chain
+			}
+		}
+		chain = append(chain, name)
+		t, err := readThemeMetadata(themeMetadataPath(name))
+		if err != nil {
+			break
+		}
+		name = mergeTheme(t, findTheme(name)).Extends
+	}
+	return chain
+}
+
+// mergeTheme returns base with every setting that is set in override replaced.
+// Variants are merged by name, wallpapers by output, i3 colors by class.
+func mergeTheme(base, override Theme) Theme {
+	if override.Description != "" {
+		base.Description = override.Description
+	}
+	if override.Author != "" {
+		base.Author = override.Author
+	}
+	if len(override.Bars) > 0 {
+		base.Bars = override.Bars
+	}
+	if len(override.Roles) > 0 {
+		base.Roles = override.Roles
+	}
+	if len(override.Fonts) > 0 {
+		base.Fonts = override.Fonts
+	}
+	if len(override.Wallpapers) > 0 {
+		wallpapers := map[string]Wallpaper{}
+		for output, w := range base.Wallpapers {
+			wallpapers[output] = w
+		}
+		for output, w := range override.Wallpapers {
+			wallpapers[output] = w
+		}
+		base.Wallpapers = wallpapers
+	}
+	if override.Backend != "" {
+		base.Backend = override.Backend
+	}
+	if override.Extends != "" {
+		base.Extends = override.Extends
+	}
+	if len(override.Variants) > 0 {
+		variants := map[string]Theme{}
+		for name, v := range base.Variants {
+			variants[name] = v
+		}
+		for name, v := range override.Variants {
+			variants[name] = mergeTheme(variants[name], v)
+		}
+		base.Variants = variants
+	}
+	base.Gaps = mergeGaps(base.Gaps, override.Gaps)
+	base.I3 = mergeI3Appearance(base.I3, override.I3)
+	base.Palette = mergePalette(base.Palette, override.Palette)
+	return base
+}
+
+func mergeGaps(base, override I3Gaps) I3Gaps {
+	if override.Top != "" {
+		base.Top = override.Top
+	}
+	if override.Bottom != "" {
+		base.Bottom = override.Bottom
+	}
+	if override.Left != "" {
+		base.Left = override.Left
+	}
+	if override.Right != "" {
+		base.Right = override.Right
+	}
+	if override.Inner != "" {
+		base.Inner = override.Inner
+	}
+	if override.Outer != "" {
+		base.Outer = override.Outer
+	}
+	return base
+}
@@ -0,0 +1,77 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDisplayProfile(t *testing.T) {
+	tests := []struct {
+		script string
+		want   string
+	}{
+		{"home_dp-4_dvi-d-0-hdmi-0.sh", "home_dp-4_dvi-d-0-hdmi-0"},
+		{"Laptop.sh", "laptop"},
+		{"work.v2.sh", "work_v2"},
+		{"desk", "desk"},
+	}
+	for _, tt := range tests {
+		if got := displayProfile(tt.script); got != tt.want {
+			t.Errorf("displayProfile(%q) = %q, want %q", tt.script, got, tt.want)
+		}
+	}
+}
+
+func TestLoadThemeProfileVariant(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dot-themes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "nord"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	metadata := `gaps:
+  top: "50"
+variants:
+  Home_DP-4_DVI-D-0-HDMI-0:
+    gaps:
+      top: "30"
+  laptop:
+    gaps:
+      top: "20"
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "nord", themeMetadataFile), []byte(metadata), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(path string, cfg config) { FullThemesPath, Config = path, cfg }(FullThemesPath, Config)
+	FullThemesPath = dir
+	Config = config{}
+
+	tests := []struct {
+		name    string
+		current string
+		want    string
+	}{
+		{"theme.yml", "", "50"},
+		{"profile script", "home_dp-4_dvi-d-0-hdmi-0.sh", "30"},
+		{"case of the script", "Laptop.sh", "20"},
+		{"no variant for the profile", "work.sh", "50"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Config.Displays.Current = tt.current
+			theme, err := loadTheme("nord")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if theme.Gaps.Top != tt.want {
+				t.Errorf("gaps.top = %q, want %q", theme.Gaps.Top, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var themePaletteCmd = &cobra.Command{
+	Use:   "palette",
+	Short: "Manage color palettes.",
+	Long: `Palettes are named sets of colors in 'palettes' in current_settings.yml. A theme
+uses one with 'palette' in its theme.yml.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+func init() {
+	themeCmd.AddCommand(themePaletteCmd)
+}
+
+// savePalette adds a palette to palettes, or replaces the palette with the same
+// name if replace is set.
+func savePalette(p Palette, replace bool) error {
+	palettes, _ := viper.Get("palettes").([]interface{})
+	entry := map[string]interface{}{
+		"name":       p.Name,
+		"background": p.Background,
+		"foreground": p.Foreground,
+		"accent":     p.Accent,
+		"urgent":     p.Urgent,
+		"colors":     p.Colors,
+	}
+	found := false
+	for i, existing := range palettes {
+		if registeredThemeName(existing) != p.Name {
+			continue
+		}
+		if !replace {
+			return fmt.Errorf("palette \"%s\" already exists, use --force to replace it", p.Name)
+		}
+		palettes[i] = entry
+		found = true
+	}
+	if !found {
+		palettes = append(palettes, entry)
+	}
+	viper.Set("palettes", palettes)
+	return viper.WriteConfig()
+}
@@ -0,0 +1,304 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/patrick-motard/dot/lib"
+	"github.com/spf13/cobra"
+)
+
+var (
+	_paletteName  string
+	_paletteLight bool
+	_paletteForce bool
+)
+
+// paletteSwatches is how many colors an image is reduced to.
+const paletteSwatches = 16
+
+// Contrast ratios the picked colors need against the background, see WCAG.
+const (
+	foregroundContrast = 7
+	colorContrast      = 4.5
+	accentContrast     = 3
+)
+
+var themePaletteFromImageCmd = &cobra.Command{
+	Use:   "from-image <file>",
+	Short: "Make a palette from the colors of an image.",
+	Long: `Reduces a PNG or JPEG image, e.g. a wallpaper, to its main colors and picks a
+background, foreground, accent and 16 terminal colors from them. Colors are made
+lighter or darker until they can be read on the background.
+
+The palette is saved to 'palettes' in current_settings.yml, named after the image
+unless --name is given.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := _paletteName
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+		}
+		p, err := paletteFromImage(args[0], _paletteLight)
+		if err != nil {
+			log.Fatal(err)
+		}
+		p.Name = name
+		printPalette(p)
+		if err := savePalette(p, _paletteForce); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("Saved palette \"%s\", use it in a theme's theme.yml with 'palette: {name: %s}'", name, name)
+	},
+}
+
+func init() {
+	themePaletteCmd.AddCommand(themePaletteFromImageCmd)
+	themePaletteFromImageCmd.Flags().StringVarP(&_paletteName, "name", "n", "", "Name of the palette. Defaults to the name of the image.")
+	themePaletteFromImageCmd.Flags().BoolVarP(&_paletteLight, "light", "l", false, "Make a palette with a light background.")
+	themePaletteFromImageCmd.Flags().BoolVarP(&_paletteForce, "force", "f", false, "Replace a palette with the same name.")
+}
+
+func paletteFromImage(path string, light bool) (Palette, error) {
+	img, err := decodeImage(path)
+	if err != nil {
+		return Palette{}, err
+	}
+	var swatches []swatch
+	for _, s := range lib.Quantize(img, paletteSwatches) {
+		c := rgb{float64(s.Color.R) / 255, float64(s.Color.G) / 255, float64(s.Color.B) / 255}
+		swatches = append(swatches, swatch{c, s.Weight})
+	}
+	if len(swatches) == 0 {
+		return Palette{}, fmt.Errorf("%s has no visible pixels", path)
+	}
+	return pickPalette(swatches, light), nil
+}
+
+// swatch is a color of an image and the share of the image it covers.
+type swatch struct {
+	rgb
+	weight float64
+}
+
+// pickPalette picks the colors of a palette from the colors of an image.
+func pickPalette(swatches []swatch, light bool) Palette {
+	black, white := rgb{0, 0, 0}, rgb{1, 1, 1}
+
+	// the background is the darkest (or lightest) color that covers a good
+	// part of the image, made dark (or light) enough to put text on
+	var common []swatch
+	for _, s := range swatches {
+		if s.weight >= 0.02 {
+			common = append(common, s)
+		}
+	}
+	if len(common) == 0 {
+		common = swatches
+	}
+	bg := common[0].rgb
+	for _, s := range common {
+		if (!light && s.luminance() < bg.luminance()) || (light && s.luminance() > bg.luminance()) {
+			bg = s.rgb
+		}
+	}
+	// colors this close to the background's color get lost on it
+	nearBg := func(c rgb) bool { return contrast(c, bg) < 1.5 }
+	for i := 0; i < 20 && !light && bg.luminance() > 0.03; i++ {
+		bg = mix(bg, black, 0.1)
+	}
+	for i := 0; i < 20 && light && bg.luminance() < 0.85; i++ {
+		bg = mix(bg, white, 0.1)
+	}
+
+	// the foreground is a light (or dark) gray, tinted with the color that
+	// stands out most from the background
+	fg := swatches[0].rgb
+	for _, s := range swatches {
+		if contrast(s.rgb, bg) > contrast(fg, bg) {
+			fg = s.rgb
+		}
+	}
+	if light {
+		fg = mix(fg, black, 0.7)
+	} else {
+		fg = mix(fg, white, 0.7)
+	}
+	fg = withContrast(fg, bg, foregroundContrast)
+
+	// the accent is a colorful color that covers a good part of the image
+	accent, score := fg, 0.0
+	for _, s := range swatches {
+		_, sat, l := s.hsl()
+		if l < 0.15 || l > 0.9 || nearBg(s.rgb) {
+			continue
+		}
+		if sc := sat * sat * math.Sqrt(s.weight); sc > score {
+			accent, score = s.rgb, sc
+		}
+	}
+	accent = withContrast(accent, bg, accentContrast)
+	_, accentSat, _ := accent.hsl()
+	sat := math.Max(accentSat, 0.45)
+	lightness := 0.6
+	if light {
+		lightness = 0.4
+	}
+
+	// terminal colors keep their usual hues, taken from the image if it has
+	// a color close enough
+	hues := []float64{0, 120, 60, 240, 300, 180} // red, green, yellow, blue, magenta, cyan
+	normal := make([]rgb, len(hues))
+	bright := make([]rgb, len(hues))
+	for i, h := range hues {
+		c := hsl(h, sat, lightness)
+		best := 30.0
+		for _, s := range swatches {
+			sh, ss, sl := s.hsl()
+			if d := hueDistance(sh, h); ss > 0.2 && sl > 0.15 && sl < 0.9 && !nearBg(s.rgb) && d < best {
+				c, best = s.rgb, d
+			}
+		}
+		normal[i] = withContrast(c, bg, colorContrast)
+		bright[i] = withContrast(mix(normal[i], fg, 0.25), bg, colorContrast)
+	}
+	urgent := normal[0]
+
+	colors := []string{withContrast(mix(bg, fg, 0.15), bg, 1.5).hex()}
+	for _, c := range normal {
+		colors = append(colors, c.hex())
+	}
+	colors = append(colors, mix(fg, bg, 0.15).hex(), withContrast(mix(bg, fg, 0.35), bg, accentContrast).hex())
+	for _, c := range bright {
+		colors = append(colors, c.hex())
+	}
+	colors = append(colors, fg.hex())
+
+	return Palette{
+		Background: bg.hex(),
+		Foreground: fg.hex(),
+		Accent:     accent.hex(),
+		Urgent:     urgent.hex(),
+		Colors:     colors,
+	}
+}
+
+func printPalette(p Palette) {
+	fmt.Printf("background  %s\n", p.Background)
+	fmt.Printf("foreground  %s\n", p.Foreground)
+	fmt.Printf("accent      %s\n", p.Accent)
+	fmt.Printf("urgent      %s\n", p.Urgent)
+	for i, c := range p.Colors {
+		fmt.Printf("color%-6d%s\n", i, c)
+	}
+}
+
+// rgb is a color with channels from 0 to 1.
+type rgb struct {
+	r, g, b float64
+}
+
+func (c rgb) hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", int(c.r*255+0.5), int(c.g*255+0.5), int(c.b*255+0.5))
+}
+
+// luminance is the relative luminance of the color as defined by WCAG.
+func (c rgb) luminance() float64 {
+	linear := func(v float64) float64 {
+		if v <= 0.03928 {
+			return v / 12.92
+		}
+		return math.Pow((v+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(c.r) + 0.7152*linear(c.g) + 0.0722*linear(c.b)
+}
+
+// contrast is the WCAG contrast ratio of two colors, from 1 to 21.
+func contrast(a, b rgb) float64 {
+	la, lb := a.luminance(), b.luminance()
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// mix returns the color t of the way from a to b.
+func mix(a, b rgb, t float64) rgb {
+	return rgb{a.r + (b.r-a.r)*t, a.g + (b.g-a.g)*t, a.b + (b.b-a.b)*t}
+}
+
+// withContrast makes c lighter or darker, away from bg, until the contrast
+// between them is at least ratio.
+func withContrast(c, bg rgb, ratio float64) rgb {
+	target := rgb{1, 1, 1}
+	if contrast(bg, rgb{0, 0, 0}) > contrast(bg, target) {
+		target = rgb{0, 0, 0}
+	}
+	for t := 0.0; t < 1; t += 0.05 {
+		if m := mix(c, target, t); contrast(m, bg) >= ratio {
+			return m
+		}
+	}
+	return target
+}
+
+// hsl returns the hue (0-360), saturation and lightness of the color.
+func (c rgb) hsl() (float64, float64, float64) {
+	max := math.Max(c.r, math.Max(c.g, c.b))
+	min := math.Min(c.r, math.Min(c.g, c.b))
+	l := (max + min) / 2
+	if max == min {
+		return 0, 0, l
+	}
+	d := max - min
+	s := d / (1 - math.Abs(2*l-1))
+	var h float64
+	switch max {
+	case c.r:
+		h = math.Mod((c.g-c.b)/d, 6)
+	case c.g:
+		h = (c.b-c.r)/d + 2
+	default:
+		h = (c.r-c.g)/d + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h, s, l
+}
+
+func hsl(h, s, l float64) rgb {
+	ch := (1 - math.Abs(2*l-1)) * s
+	x := ch * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - ch/2
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = ch, x, 0
+	case h < 120:
+		r, g, b = x, ch, 0
+	case h < 180:
+		r, g, b = 0, ch, x
+	case h < 240:
+		r, g, b = 0, x, ch
+	case h < 300:
+		r, g, b = x, 0, ch
+	default:
+		r, g, b = ch, 0, x
+	}
+	return rgb{r + m, g + m, b + m}
+}
+
+// hueDistance is the angle between two hues.
+func hueDistance(a, b float64) float64 {
+	d := math.Abs(a - b)
+	if d > 180 {
+		d = 360 - d
+	}
+	return d
+}
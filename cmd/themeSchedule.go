@@ -0,0 +1,167 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patrick-motard/dot/lib"
+	"github.com/spf13/cobra"
+)
+
+var themeScheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Switch themes by the time of day.",
+	Long: `Switches between themes, e.g. a light one during the day and a dark one at night,
+as set in polybar.schedule in current_settings.yml:
+
+polybar:
+  schedule:
+    latitude: 52.52
+    longitude: 13.40
+    times:
+    - {from: sunrise, to: sunset, theme: nord-light}
+    - {from: sunset, to: sunrise, theme: nord, palette: forest}
+
+Times are "HH:MM", "sunrise" or "sunset", optionally moved by a duration, e.g.
+"sunset-30m". Sunrise and sunset are computed from the latitude and longitude,
+no network is needed. The first range that holds the current time wins.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+func init() {
+	themeCmd.AddCommand(themeScheduleCmd)
+}
+
+// scheduleTime returns the time a schedule time stands for on the day of date.
+func scheduleTime(spec string, date time.Time) (time.Time, error) {
+	s := strings.ToLower(strings.TrimSpace(spec))
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	for _, event := range []string{"sunrise", "sunset"} {
+		if !strings.HasPrefix(s, event) {
+			continue
+		}
+		var offset time.Duration
+		if rest := s[len(event):]; rest != "" {
+			d, err := time.ParseDuration(strings.TrimPrefix(rest, "+"))
+			if err != nil || (rest[0] != '+' && rest[0] != '-') {
+				return time.Time{}, fmt.Errorf("invalid schedule time \"%s\", expected e.g. %s+30m", spec, event)
+			}
+			offset = d
+		}
+		sched := Config.Polybar.Schedule
+		if sched.Latitude == 0 && sched.Longitude == 0 {
+			return time.Time{}, fmt.Errorf("\"%s\" needs polybar.schedule.latitude and longitude", spec)
+		}
+		sunrise, sunset, ok := lib.Sun(day.Add(12*time.Hour), sched.Latitude, sched.Longitude)
+		if !ok {
+			return time.Time{}, fmt.Errorf("the sun doesn't rise or set on %s", day.Format("2006-01-02"))
+		}
+		if event == "sunrise" {
+			return sunrise.Add(offset), nil
+		}
+		return sunset.Add(offset), nil
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) == 2 {
+		h, herr := strconv.Atoi(parts[0])
+		m, merr := strconv.Atoi(parts[1])
+		if herr == nil && merr == nil && h >= 0 && h <= 24 && m >= 0 && m < 60 && h*60+m <= 24*60 {
+			return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid schedule time \"%s\", expected HH:MM, sunrise or sunset", spec)
+}
+
+// scheduleRange returns when a scheduled theme starts and ends on the day of
+// date. Ranges that go past midnight end the next day.
+func scheduleRange(s ScheduledTheme, date time.Time) (time.Time, time.Time, error) {
+	from, err := scheduleTime(s.From, date)
+	if err != nil {
+		return from, from, err
+	}
+	to, err := scheduleTime(s.To, date)
+	if err != nil {
+		return from, to, err
+	}
+	if !to.After(from) {
+		if to, err = scheduleTime(s.To, date.AddDate(0, 0, 1)); err != nil {
+			return from, to, err
+		}
+	}
+	return from, to, nil
+}
+
+// scheduledTheme returns the index of the scheduled theme for t, or -1 if no
+// range holds it.
+func scheduledTheme(times []ScheduledTheme, t time.Time) (int, error) {
+	for i, s := range times {
+		// a range that started yesterday may still be going
+		for _, day := range []time.Time{t.AddDate(0, 0, -1), t} {
+			from, to, err := scheduleRange(s, day)
+			if err != nil {
+				return -1, err
+			}
+			if !t.Before(from) && t.Before(to) {
+				return i, nil
+			}
+		}
+	}
+	return -1, nil
+}
+
+// nextScheduleChange returns the first start or end of a range after t.
+func nextScheduleChange(times []ScheduledTheme, t time.Time) (time.Time, error) {
+	var next time.Time
+	for _, s := range times {
+		for _, day := range []time.Time{t, t.AddDate(0, 0, 1)} {
+			for _, spec := range []string{s.From, s.To} {
+				at, err := scheduleTime(spec, day)
+				if err != nil {
+					return next, err
+				}
+				if at.After(t) && (next.IsZero() || at.Before(next)) {
+					next = at
+				}
+			}
+		}
+	}
+	return next, nil
+}
+
+// checkSchedule checks that every scheduled theme is installed and every time
+// can be read.
+func checkSchedule(times []ScheduledTheme) error {
+	if len(times) == 0 {
+		return fmt.Errorf("polybar.schedule.times is empty")
+	}
+	FullThemesPath = Home + "/" + Config.Polybar.ThemesDirectory
+	now := time.Now()
+	for i, s := range times {
+		if s.Theme == "" {
+			return fmt.Errorf("scheduled theme %d has no theme", i+1)
+		}
+		if name, _ := splitThemeName(s.Theme); !isThemeDir(filepath.Join(FullThemesPath, name)) {
+			return fmt.Errorf("scheduled theme \"%s\" is not installed", s.Theme)
+		}
+		if s.Palette != "" {
+			found := false
+			for _, p := range Config.Palettes {
+				found = found || p.Name == s.Palette
+			}
+			if !found {
+				return fmt.Errorf("scheduled palette \"%s\" is not defined in palettes", s.Palette)
+			}
+		}
+		if _, _, err := scheduleRange(s, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var _scheduleOnce bool
+
+// scheduleCheckInterval is how often the schedule is looked at, at least. It
+// catches up after a suspend, which time.Sleep doesn't count.
+const scheduleCheckInterval = time.Minute
+
+var themeScheduleRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Load the scheduled theme now and whenever the schedule changes to another.",
+	Long: `Loads the theme (and palette) scheduled for now, then keeps running and loads the
+next one when its time comes. Run it when you log in, e.g. in your i3 config:
+
+exec --no-startup-id dot theme schedule run
+
+A theme you load yourself stays until the schedule changes to the next theme.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := checkSchedule(Config.Polybar.Schedule.Times); err != nil {
+			log.Fatal(err)
+		}
+		var current ScheduledTheme
+		started := false
+		for {
+			now := time.Now()
+			times := Config.Polybar.Schedule.Times
+			if i, err := scheduledTheme(times, now); err != nil {
+				log.Errorln(err)
+			} else if i < 0 && (!started || current != ScheduledTheme{}) {
+				log.Infof("No theme is scheduled for %s, keeping the current theme", now.Format("15:04"))
+				current, started = ScheduledTheme{}, true
+			} else if i >= 0 && (!started || current != times[i]) {
+				if err := loadScheduledTheme(times[i]); err != nil {
+					log.Errorf("Failed to load scheduled theme \"%s\": %s", times[i].Theme, err)
+				}
+				current, started = times[i], true
+			}
+			if _scheduleOnce {
+				return
+			}
+
+			wait := scheduleCheckInterval
+			if next, err := nextScheduleChange(times, now); err == nil && !next.IsZero() && next.Sub(now) < wait {
+				// wake up just after the change, not just before it
+				wait = next.Sub(now) + time.Second
+			}
+			time.Sleep(wait)
+			// the settings may have changed since dot started
+			if err := rereadConfig(); err != nil {
+				log.Errorln(err)
+			}
+		}
+	},
+}
+
+func init() {
+	themeScheduleCmd.AddCommand(themeScheduleRunCmd)
+	themeScheduleRunCmd.Flags().BoolVar(&_scheduleOnce, "once", false, "Load the scheduled theme and exit.")
+}
+
+// loadScheduledTheme loads a theme with 'dot polybar', which takes care of the
+// bars, the palette and the wallpapers.
+func loadScheduledTheme(s ScheduledTheme) error {
+	if s.Palette != "" {
+		log.Infof("Loading scheduled theme \"%s\" with palette \"%s\"", s.Theme, s.Palette)
+	} else {
+		log.Infof("Loading scheduled theme \"%s\"", s.Theme)
+	}
+	return runDot("polybar", "--theme", s.Theme, "--palette", s.Palette)
+}
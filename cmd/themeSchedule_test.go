@@ -0,0 +1,137 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+// berlin is Berlin in summer, on the summer solstice of 2024 the sun rises at
+// 04:43 and sets at 21:33.
+var berlin = time.FixedZone("CEST", 2*3600)
+
+// solstice returns a time on the summer solstice of 2024 in Berlin, days moves
+// it to another day.
+func solstice(hhmm string, days int) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", "2024-06-21 "+hhmm, berlin)
+	if err != nil {
+		panic(err)
+	}
+	return t.AddDate(0, 0, days)
+}
+
+// withBerlinSchedule sets the location of the schedule to Berlin until the
+// returned func is called.
+func withBerlinSchedule() func() {
+	old := Config.Polybar.Schedule
+	Config.Polybar.Schedule = ThemeSchedule{Latitude: 52.52, Longitude: 13.405}
+	return func() { Config.Polybar.Schedule = old }
+}
+
+// closeTo reports whether two times are less than a minute apart, sunrise and
+// sunset are accurate to about a minute.
+func closeTo(a, b time.Time) bool {
+	d := a.Sub(b)
+	return d > -time.Minute && d < time.Minute
+}
+
+func TestScheduleTime(t *testing.T) {
+	defer withBerlinSchedule()()
+	date := solstice("12:00", 0)
+	tests := []struct {
+		spec string
+		want time.Time
+		err  bool
+	}{
+		{spec: "07:30", want: solstice("07:30", 0)},
+		{spec: "0:05", want: solstice("00:05", 0)},
+		{spec: "24:00", want: solstice("00:00", 1)},
+		{spec: "sunrise", want: solstice("04:43", 0)},
+		{spec: "sunset", want: solstice("21:33", 0)},
+		{spec: " Sunset ", want: solstice("21:33", 0)},
+		{spec: "sunset-30m", want: solstice("21:03", 0)},
+		{spec: "sunrise+1h30m", want: solstice("06:13", 0)},
+		{spec: "sunset30m", err: true},
+		{spec: "sunset-", err: true},
+		{spec: "sunrise+x", err: true},
+		{spec: "noon", err: true},
+		{spec: "7", err: true},
+		{spec: "25:00", err: true},
+		{spec: "24:01", err: true},
+		{spec: "12:60", err: true},
+	}
+	for _, tt := range tests {
+		got, err := scheduleTime(tt.spec, date)
+		if tt.err {
+			if err == nil {
+				t.Errorf("scheduleTime(%q) = %s, want an error", tt.spec, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("scheduleTime(%q): %s", tt.spec, err)
+			continue
+		}
+		if !closeTo(got, tt.want) {
+			t.Errorf("scheduleTime(%q) = %s, want %s", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleTimeNeedsLocation(t *testing.T) {
+	old := Config.Polybar.Schedule
+	defer func() { Config.Polybar.Schedule = old }()
+	Config.Polybar.Schedule = ThemeSchedule{}
+	if _, err := scheduleTime("sunset", solstice("12:00", 0)); err == nil {
+		t.Error("got no error for sunset without a latitude and longitude")
+	}
+	if _, err := scheduleTime("22:00", solstice("12:00", 0)); err != nil {
+		t.Errorf("got %s for a time of day without a latitude and longitude", err)
+	}
+}
+
+func TestScheduledTheme(t *testing.T) {
+	defer withBerlinSchedule()()
+	dayAndNight := []ScheduledTheme{
+		{From: "sunrise", To: "sunset", Theme: "nord-light"},
+		{From: "sunset", To: "sunrise", Theme: "nord"},
+	}
+	evening := []ScheduledTheme{
+		{From: "22:00", To: "06:00", Theme: "nord"},
+	}
+	tests := []struct {
+		name   string
+		times  []ScheduledTheme
+		at     time.Time
+		want   int
+		change time.Time
+	}{
+		{"day", dayAndNight, solstice("12:00", 0), 0, solstice("21:33", 0)},
+		{"at sunrise", dayAndNight, solstice("04:44", 0), 0, solstice("21:33", 0)},
+		{"evening", dayAndNight, solstice("23:00", 0), 1, solstice("04:43", 1)},
+		{"after midnight", dayAndNight, solstice("02:00", 0), 1, solstice("04:43", 0)},
+		{"before a range that crosses midnight", evening, solstice("21:59", 0), -1, solstice("22:00", 0)},
+		{"in a range that crosses midnight", evening, solstice("23:30", 0), 0, solstice("06:00", 1)},
+		{"in a range that started yesterday", evening, solstice("05:59", 0), 0, solstice("06:00", 0)},
+		{"after a range that crosses midnight", evening, solstice("06:00", 0), -1, solstice("22:00", 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scheduledTheme(tt.times, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("scheduledTheme() = %d, want %d", got, tt.want)
+			}
+			change, err := nextScheduleChange(tt.times, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !closeTo(change, tt.change) {
+				t.Errorf("nextScheduleChange() = %s, want %s", change, tt.change)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	// register the image formats image.Decode understands
+	_ "image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+	"github.com/patrick-motard/dot/lib"
+	"github.com/spf13/cobra"
+)
+
+var wallpaperCmd = &cobra.Command{
+	Use:   "wallpaper",
+	Short: "Set the wallpaper of each display.",
+	Long: `Draws a wallpaper on every active output. Wallpapers come from, first to last:
+- 'dot wallpaper set', saved in wallpaper.outputs
+- the current display profile, in displays.wallpapers.<profile>
+- the current theme, in 'wallpapers' in its theme.yml
+
+Each of them maps outputs to wallpapers. An output is named by its name (DP-4), a
+display alias, "primary" or "all". A wallpaper is an image or a directory of
+images, see 'dot wallpaper rotate'.
+
+dot draws the wallpapers again whenever it applies a display layout or a theme.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var wallpaperApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Draw the wallpapers again, e.g. when X starts.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := applyWallpapers(false); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(wallpaperCmd)
+	wallpaperCmd.AddCommand(wallpaperApplyCmd)
+}
+
+// wallpaperLayer is a set of wallpapers by output, e.g. the ones of a theme.
+type wallpaperLayer struct {
+	Source     string
+	Wallpapers map[string]Wallpaper
+	// Dirs are where relative paths are looked up.
+	Dirs []string
+}
+
+// wallpaperLayers returns the wallpapers that are configured, the ones that win
+// first.
+func wallpaperLayers() []wallpaperLayer {
+	set := map[string]Wallpaper{}
+	for _, w := range Config.Wallpaper.Outputs {
+		set[w.Output] = w
+	}
+	layers := []wallpaperLayer{{Source: "dot wallpaper set", Wallpapers: set}}
+	// profiles are keyed like theme variants, see displayProfile
+	if wallpapers, ok := Config.Displays.Wallpapers[displayProfile(Config.Displays.Current)]; ok && Config.Displays.Current != "" {
+		layers = append(layers, wallpaperLayer{Source: "display profile " + Config.Displays.Current, Wallpapers: wallpapers})
+	}
+	if Config.Polybar.Theme != "" {
+		FullThemesPath = Home + "/" + Config.Polybar.ThemesDirectory
+		t, err := loadTheme(Config.Polybar.Theme)
+		if err != nil {
+			log.Warnf("Skipping the wallpapers of theme \"%s\": %s", Config.Polybar.Theme, err)
+		} else if len(t.Wallpapers) > 0 {
+			// the wallpapers may come from a theme this one extends
+			var dirs []string
+			for _, name := range themeChain(t.Name) {
+				dirs = append(dirs, filepath.Join(FullThemesPath, name))
+			}
+			layers = append(layers, wallpaperLayer{Source: "theme " + t.Name, Wallpapers: t.Wallpapers, Dirs: dirs})
+		}
+	}
+	return layers
+}
+
+// findWallpaper returns the wallpaper of an output and where it was set. In
+// each layer, the output's name wins over its aliases, "primary" and "all".
+// Keys are compared ignoring case, viper lowercases them.
+func findWallpaper(layers []wallpaperLayer, o wallpaperOutput) (Wallpaper, string, bool) {
+	keys := []string{o.Name}
+	for alias, output := range Config.Displays.Aliases {
+		if output == o.Name {
+			keys = append(keys, alias)
+		}
+	}
+	if o.Primary {
+		keys = append(keys, "primary")
+	}
+	keys = append(keys, "all")
+	for _, l := range layers {
+		for _, key := range keys {
+			for k, w := range l.Wallpapers {
+				if strings.EqualFold(k, key) && w.Path != "" {
+					w.Path = wallpaperPath(w.Path, l.Dirs)
+					return w, l.Source, true
+				}
+			}
+		}
+	}
+	return Wallpaper{}, "", false
+}
+
+// wallpaperPath expands ~ and looks up relative paths in dirs.
+func wallpaperPath(p string, dirs []string) string {
+	if strings.HasPrefix(p, "~/") {
+		return filepath.Join(Home, p[2:])
+	}
+	if filepath.IsAbs(p) {
+		return p
+	}
+	for _, dir := range dirs {
+		if _, err := os.Stat(filepath.Join(dir, p)); err == nil {
+			return filepath.Join(dir, p)
+		}
+	}
+	return p
+}
+
+// wallpaperImage returns the image to show on an output. For a directory it's
+// the image that was shown last, or the one after it if next is set.
+func wallpaperImage(output, path string, shown map[string]string, next bool) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return path, nil
+	}
+	images, err := wallpaperImages(path)
+	if err != nil {
+		return "", err
+	}
+	if len(images) == 0 {
+		return "", fmt.Errorf("no PNG or JPEG images found in %s", path)
+	}
+	i := -1
+	for j, img := range images {
+		if img == shown[strings.ToLower(output)] {
+			i = j
+		}
+	}
+	if next {
+		i = (i + 1) % len(images)
+	} else if i < 0 {
+		i = 0
+	}
+	return images[i], nil
+}
+
+// wallpaperImages returns the images in a directory, sorted by name.
+func wallpaperImages(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var images []string
+	for _, f := range files {
+		switch strings.ToLower(filepath.Ext(f.Name())) {
+		case ".png", ".jpg", ".jpeg":
+			images = append(images, filepath.Join(dir, f.Name()))
+		}
+	}
+	return images, nil
+}
+
+func decodeImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %s", path, err)
+	}
+	return img, nil
+}
+
+// applyWallpapers draws the wallpaper of every active output onto the root
+// window. With next set, directories move on to their next image. The root
+// window is left alone if no output has a wallpaper.
+func applyWallpapers(next bool) error {
+	X, err := xgb.NewConn()
+	if err != nil {
+		return fmt.Errorf("can't connect to X: %s", err)
+	}
+	defer X.Close()
+	screen := xproto.Setup(X).DefaultScreen(X)
+	outputs, err := activeOutputs(X, screen.Root)
+	if err != nil {
+		return err
+	}
+
+	state, err := readWallpaperState()
+	if err != nil {
+		return err
+	}
+	layers := wallpaperLayers()
+	canvas := image.NewRGBA(image.Rect(0, 0, int(screen.WidthInPixels), int(screen.HeightInPixels)))
+	images := map[string]image.Image{}
+	shown := map[string]string{}
+	for _, o := range outputs {
+		w, source, ok := findWallpaper(layers, o)
+		if !ok {
+			log.Infof("No wallpaper for %s", o.Name)
+			continue
+		}
+		path, err := wallpaperImage(o.Name, w.Path, state.Shown, next)
+		if err != nil {
+			return err
+		}
+		img, ok := images[path]
+		if !ok {
+			if img, err = decodeImage(path); err != nil {
+				return err
+			}
+			images[path] = img
+		}
+		mode := w.Mode
+		if mode == "" {
+			mode = lib.WallpaperFill
+		}
+		if err := lib.DrawWallpaper(canvas, o.Rect, img, mode); err != nil {
+			return err
+		}
+		log.Infof("Wallpaper of %s: %s (%s, from %s)", o.Name, path, mode, source)
+		if path != w.Path {
+			shown[o.Name] = path
+		}
+	}
+	if len(images) == 0 {
+		return nil
+	}
+	if err := setRootImage(X, screen, canvas); err != nil {
+		return err
+	}
+	return state.saveShown(shown)
+}
+
+// wallpaperState is what dot keeps about the wallpapers in its state.
+type wallpaperState struct {
+	// Shown are the images shown from wallpaper directories, by output in
+	// lower case.
+	Shown map[string]string `json:"shown"`
+}
+
+func wallpaperStateFile() string {
+	return filepath.Join(stateDir(), "wallpaper.json")
+}
+
+func readWallpaperState() (wallpaperState, error) {
+	s := wallpaperState{Shown: map[string]string{}}
+	data, err := ioutil.ReadFile(wallpaperStateFile())
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("failed to parse %s: %s", wallpaperStateFile(), err)
+	}
+	if s.Shown == nil {
+		s.Shown = map[string]string{}
+	}
+	return s, nil
+}
+
+func (s wallpaperState) write() error {
+	if err := os.MkdirAll(stateDir(), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(wallpaperStateFile(), data, 0644)
+}
+
+// saveShown remembers the images shown from directories, so they stay the
+// same until the next rotation.
+func (s wallpaperState) saveShown(shown map[string]string) error {
+	changed := false
+	for output, path := range shown {
+		if s.Shown[strings.ToLower(output)] != path {
+			s.Shown[strings.ToLower(output)] = path
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.write()
+}
+
+// reapplyWallpapers draws the wallpapers again after the layout or the theme
+// changed.
+func reapplyWallpapers() {
+	if err := applyWallpapers(false); err != nil {
+		log.Warnf("Failed to set the wallpaper: %s", err)
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var wallpaperResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Forget the wallpapers set with 'dot wallpaper set'.",
+	Long: `Removes the wallpapers set with 'dot wallpaper set', or only the one of --output,
+and draws the wallpapers of the display profile and the theme again.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var outputs []Wallpaper
+		if _wallpaperOutput != "" {
+			for _, w := range Config.Wallpaper.Outputs {
+				if !strings.EqualFold(w.Output, _wallpaperOutput) {
+					outputs = append(outputs, w)
+				}
+			}
+		}
+		if err := saveWallpapers(outputs); err != nil {
+			log.Fatal(err)
+		}
+		if err := applyWallpapers(false); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	wallpaperCmd.AddCommand(wallpaperResetCmd)
+	wallpaperResetCmd.Flags().StringVarP(&_wallpaperOutput, "output", "o", "", "Only forget the wallpaper of this output.")
+}
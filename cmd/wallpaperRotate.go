@@ -0,0 +1,44 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var _wallpaperInterval time.Duration
+
+var wallpaperRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Show the next image of wallpapers that are directories.",
+	Long: `Moves every wallpaper that is a directory on to its next image, in order of the
+file names. Wallpapers that are a single image stay the same.
+
+With --interval, dot keeps running and rotates the wallpapers every interval, e.g.
+'dot wallpaper rotate --interval 30m' in your i3 config.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := applyWallpapers(true); err != nil {
+			log.Fatal(err)
+		}
+		if _wallpaperInterval <= 0 {
+			return
+		}
+		for range time.Tick(_wallpaperInterval) {
+			// the settings may have changed since dot started
+			if err := rereadConfig(); err != nil {
+				log.Errorln(err)
+			}
+			if err := applyWallpapers(true); err != nil {
+				log.Errorln(err)
+			}
+		}
+	},
+}
+
+func init() {
+	wallpaperCmd.AddCommand(wallpaperRotateCmd)
+	wallpaperRotateCmd.Flags().DurationVarP(&_wallpaperInterval, "interval", "i", 0, "Keep rotating the wallpapers every interval, e.g. 30m.")
+}
@@ -0,0 +1,100 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/patrick-motard/dot/lib"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	_wallpaperOutput string
+	_wallpaperMode   string
+)
+
+var wallpaperSetCmd = &cobra.Command{
+	Use:   "set <file|dir>",
+	Short: "Set the wallpaper of every output, or of one with --output.",
+	Long: `Sets an image, or a directory of images, as the wallpaper and draws it. The
+wallpaper is saved to wallpaper.outputs and wins over the wallpapers of the display
+profile and the theme until 'dot wallpaper reset'.
+
+Without --output the wallpaper is shown on every output, replacing wallpapers set for
+single outputs before. --output takes an output name, a display alias or "primary".
+
+Modes:
+- fill: scale the image to cover the output, cutting off the edges (default)
+- fit: scale the image to fit on the output, with black bars
+- center: center the image without scaling it
+- tile: repeat the image from the top left corner`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := absPath(args[0])
+		if err := checkWallpaper(path, _wallpaperMode); err != nil {
+			log.Fatal(err)
+		}
+		output := _wallpaperOutput
+		if output == "" {
+			output = "all"
+		}
+		var outputs []Wallpaper
+		for _, w := range Config.Wallpaper.Outputs {
+			if output != "all" && !strings.EqualFold(w.Output, output) {
+				outputs = append(outputs, w)
+			}
+		}
+		outputs = append(outputs, Wallpaper{Path: path, Mode: _wallpaperMode, Output: output})
+		if err := saveWallpapers(outputs); err != nil {
+			log.Fatal(err)
+		}
+		if err := applyWallpapers(false); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	wallpaperCmd.AddCommand(wallpaperSetCmd)
+	wallpaperSetCmd.Flags().StringVarP(&_wallpaperOutput, "output", "o", "", "Only set the wallpaper of this output.")
+	wallpaperSetCmd.Flags().StringVarP(&_wallpaperMode, "mode", "m", lib.WallpaperFill, fmt.Sprintf("How to draw the image, one of: %v", lib.WallpaperModes))
+}
+
+// checkWallpaper checks that a wallpaper can be drawn before it is saved.
+func checkWallpaper(path, mode string) error {
+	valid := false
+	for _, m := range lib.WallpaperModes {
+		valid = valid || m == mode
+	}
+	if !valid {
+		return fmt.Errorf("unknown mode \"%s\", expected one of: %v", mode, lib.WallpaperModes)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		images, err := wallpaperImages(path)
+		if err == nil && len(images) == 0 {
+			err = fmt.Errorf("no PNG or JPEG images found in %s", path)
+		}
+		return err
+	}
+	_, err = decodeImage(path)
+	return err
+}
+
+// saveWallpapers writes the wallpapers set with 'dot wallpaper set'.
+func saveWallpapers(outputs []Wallpaper) error {
+	Config.Wallpaper.Outputs = outputs
+	values := []interface{}{}
+	for _, w := range outputs {
+		values = append(values, map[string]interface{}{"output": w.Output, "path": w.Path, "mode": w.Mode})
+	}
+	viper.Set("wallpaper.outputs", values)
+	return viper.WriteConfig()
+}
@@ -0,0 +1,156 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/randr"
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+// wallpaperOutput is an active output and where it is on the screen.
+type wallpaperOutput struct {
+	Name    string
+	Primary bool
+	Rect    image.Rectangle
+}
+
+// activeOutputs returns the outputs that show part of the screen. The size
+// comes from the output's CRTC, so rotated and scaled outputs are right.
+func activeOutputs(X *xgb.Conn, root xproto.Window) ([]wallpaperOutput, error) {
+	if err := randr.Init(X); err != nil {
+		return nil, err
+	}
+	resources, err := randr.GetScreenResources(X, root).Reply()
+	if err != nil {
+		return nil, err
+	}
+	primary, _ := randr.GetOutputPrimary(X, root).Reply()
+	var outputs []wallpaperOutput
+	for _, output := range resources.Outputs {
+		info, err := randr.GetOutputInfo(X, output, 0).Reply()
+		if err != nil {
+			return nil, err
+		}
+		if info.Connection != randr.ConnectionConnected || info.Crtc == 0 {
+			continue
+		}
+		crtc, err := randr.GetCrtcInfo(X, info.Crtc, 0).Reply()
+		if err != nil {
+			continue
+		}
+		outputs = append(outputs, wallpaperOutput{
+			Name:    string(info.Name),
+			Primary: primary != nil && output == primary.Output,
+			Rect:    image.Rect(int(crtc.X), int(crtc.Y), int(crtc.X)+int(crtc.Width), int(crtc.Y)+int(crtc.Height)),
+		})
+	}
+	return outputs, nil
+}
+
+// setRootImage makes img the background of the root window. Like Esetroot,
+// the pixmap is kept after dot exits and announced through _XROOTPMAP_ID, so
+// terminals and compositors with fake transparency find it. The pixmap of the
+// previous wallpaper is freed.
+func setRootImage(X *xgb.Conn, screen *xproto.ScreenInfo, img *image.RGBA) error {
+	setup := xproto.Setup(X)
+	depth := screen.RootDepth
+	bpp := 0
+	for _, f := range setup.PixmapFormats {
+		if f.Depth == depth {
+			bpp = int(f.BitsPerPixel)
+		}
+	}
+	if bpp != 32 {
+		return fmt.Errorf("root windows with depth %d and %d bits per pixel are not supported", depth, bpp)
+	}
+	width, height := int(screen.WidthInPixels), int(screen.HeightInPixels)
+
+	pixmap, err := xproto.NewPixmapId(X)
+	if err != nil {
+		return err
+	}
+	if err := xproto.CreatePixmapChecked(X, depth, pixmap, xproto.Drawable(screen.Root), uint16(width), uint16(height)).Check(); err != nil {
+		return fmt.Errorf("failed to create the wallpaper pixmap: %s", err)
+	}
+	gc, err := xproto.NewGcontextId(X)
+	if err != nil {
+		return err
+	}
+	if err := xproto.CreateGCChecked(X, gc, xproto.Drawable(pixmap), 0, nil).Check(); err != nil {
+		return err
+	}
+	defer xproto.FreeGC(X, gc)
+
+	// send the image in strips that fit in a request, the PutImage header
+	// takes 24 bytes
+	rows := (int(setup.MaximumRequestLength)*4 - 24) / (width * 4)
+	if rows < 1 {
+		return fmt.Errorf("the screen is too wide to set a wallpaper")
+	}
+	for y := 0; y < height; y += rows {
+		n := rows
+		if y+n > height {
+			n = height - y
+		}
+		data := make([]byte, 0, width*n*4)
+		for row := y; row < y+n; row++ {
+			for x := 0; x < width; x++ {
+				var r, g, b uint8
+				if (image.Point{x, row}).In(img.Bounds()) {
+					i := img.PixOffset(x, row)
+					r, g, b = img.Pix[i], img.Pix[i+1], img.Pix[i+2]
+				}
+				if setup.ImageByteOrder == xproto.ImageOrderLSBFirst {
+					data = append(data, b, g, r, 0)
+				} else {
+					data = append(data, 0, r, g, b)
+				}
+			}
+		}
+		err := xproto.PutImageChecked(X, xproto.ImageFormatZPixmap, xproto.Drawable(pixmap), gc,
+			uint16(width), uint16(n), 0, int16(y), 0, depth, data).Check()
+		if err != nil {
+			return fmt.Errorf("failed to draw the wallpaper: %s", err)
+		}
+	}
+
+	var atoms []xproto.Atom
+	for _, name := range []string{"_XROOTPMAP_ID", "ESETROOT_PMAP_ID"} {
+		reply, err := xproto.InternAtom(X, false, uint16(len(name)), name).Reply()
+		if err != nil {
+			return err
+		}
+		atoms = append(atoms, reply.Atom)
+	}
+	// the previous wallpaper's pixmap was kept by the program that set it,
+	// killing it frees the pixmap. Both properties name it if it was set the
+	// Esetroot way.
+	var old []uint32
+	for _, atom := range atoms {
+		prop, err := xproto.GetProperty(X, false, screen.Root, atom, xproto.AtomPixmap, 0, 1).Reply()
+		if err == nil && len(prop.Value) == 4 {
+			old = append(old, xgb.Get32(prop.Value))
+		}
+	}
+	if len(old) == 2 && old[0] == old[1] {
+		xproto.KillClientChecked(X, old[0]).Check()
+	}
+	value := make([]byte, 4)
+	xgb.Put32(value, uint32(pixmap))
+	for _, atom := range atoms {
+		if err := xproto.ChangePropertyChecked(X, xproto.PropModeReplace, screen.Root, atom, xproto.AtomPixmap, 32, 1, value).Check(); err != nil {
+			return err
+		}
+	}
+	if err := xproto.ChangeWindowAttributesChecked(X, screen.Root, xproto.CwBackPixmap, []uint32{uint32(pixmap)}).Check(); err != nil {
+		return err
+	}
+	if err := xproto.ClearAreaChecked(X, false, screen.Root, 0, 0, 0, 0).Check(); err != nil {
+		return err
+	}
+	return xproto.SetCloseDownModeChecked(X, xproto.CloseDownRetainPermanent).Check()
+}
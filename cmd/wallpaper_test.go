@@ -0,0 +1,53 @@
+// Copyright © 2018 Patrick Motard <motard19@gmail.com>
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestWallpaperLayersProfile(t *testing.T) {
+	settings := `displays:
+  wallpapers:
+    home_dp-4_dvi-d-0-hdmi-0:
+      primary: {path: ~/Pictures/forest.jpg}
+    Work:
+      all: {path: ~/Pictures/dark}
+`
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(strings.NewReader(settings)); err != nil {
+		t.Fatal(err)
+	}
+	defer func(cfg config) { Config = cfg }(Config)
+	Config = config{}
+	if err := v.Unmarshal(&Config); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		current string
+		want    string
+	}{
+		{"home_dp-4_dvi-d-0-hdmi-0.sh", "~/Pictures/forest.jpg"},
+		{"work.sh", "~/Pictures/dark"},
+		{"laptop.sh", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		Config.Displays.Current = tt.current
+		layers := wallpaperLayers()
+		var got string
+		if len(layers) > 1 {
+			for _, w := range layers[1].Wallpapers {
+				got = w.Path
+			}
+		}
+		if got != tt.want {
+			t.Errorf("profile %q: got wallpaper %q, want %q", tt.current, got, tt.want)
+		}
+	}
+}
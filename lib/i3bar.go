@@ -0,0 +1,114 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// I3barConfig is a file of i3 'bar { ... }' blocks.
+type I3barConfig struct {
+	Path string
+	Bars []*I3bar
+}
+
+// I3bar is a 'bar { ... }' block of an i3 config.
+type I3bar struct {
+	ID   string
+	File string
+	Line int
+	// Lines are the lines between the braces, except for the id.
+	Lines []string
+}
+
+// Pos returns the position of the block as "file:line".
+func (b *I3bar) Pos() string {
+	return fmt.Sprintf("%s:%d", b.File, b.Line)
+}
+
+// Get returns the value of a setting of the bar, e.g. status_command. Settings
+// in nested blocks, like colors, are not returned.
+func (b *I3bar) Get(key string) (string, bool) {
+	depth := 0
+	for _, line := range b.Lines {
+		text := strings.TrimSpace(line)
+		if depth == 0 {
+			fields := strings.SplitN(text, " ", 2)
+			if fields[0] == key {
+				if len(fields) == 1 {
+					return "", true
+				}
+				return strings.TrimSpace(fields[1]), true
+			}
+		}
+		depth += strings.Count(text, "{") - strings.Count(text, "}")
+	}
+	return "", false
+}
+
+// ParseI3barConfig parses a file that holds only bar blocks, each with an id.
+func ParseI3barConfig(path string) (*I3barConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := &I3barConfig{Path: path}
+	var bar *I3bar
+	depth := 0
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if bar == nil {
+			if text != "bar {" && text != "bar{" {
+				return nil, fmt.Errorf("%s:%d: expected 'bar {', got %q", path, line, text)
+			}
+			bar = &I3bar{File: path, Line: line}
+			depth = 1
+			continue
+		}
+		depth += strings.Count(text, "{") - strings.Count(text, "}")
+		if depth == 0 {
+			if bar.ID == "" {
+				return nil, fmt.Errorf("%s: bar has no id", bar.Pos())
+			}
+			for _, b := range c.Bars {
+				if b.ID == bar.ID {
+					return nil, fmt.Errorf("%s: duplicate bar %q, first defined at %s", bar.Pos(), bar.ID, b.Pos())
+				}
+			}
+			c.Bars = append(c.Bars, bar)
+			bar = nil
+			continue
+		}
+		if fields := strings.Fields(text); depth == 1 && len(fields) == 2 && fields[0] == "id" {
+			bar.ID = fields[1]
+			continue
+		}
+		bar.Lines = append(bar.Lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if bar != nil {
+		return nil, fmt.Errorf("%s: bar is missing its closing '}'", bar.Pos())
+	}
+	return c, nil
+}
+
+// Bar returns the bar with the given id.
+func (c *I3barConfig) Bar(id string) (*I3bar, bool) {
+	for _, b := range c.Bars {
+		if b.ID == id {
+			return b, true
+		}
+	}
+	return nil, false
+}
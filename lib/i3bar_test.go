@@ -0,0 +1,116 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseI3barConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		// want are the ids of the bars and the line each starts on.
+		want []string
+		err  string
+	}{
+		{
+			name:   "bars",
+			config: "# bars of the theme\nbar {\n    id top\n    position top\n}\n\nbar{\n    id bottom\n}\n",
+			want:   []string{"top:2", "bottom:7"},
+		},
+		{
+			name:   "nested blocks",
+			config: "bar {\n    colors {\n        id not-the-bar\n    }\n    id main\n}\n",
+			want:   []string{"main:1"},
+		},
+		{
+			name:   "not a bar",
+			config: "bar {\n    id main\n}\nfont pango:monospace 8\n",
+			err:    `:4: expected 'bar {', got "font pango:monospace 8"`,
+		},
+		{
+			name:   "bar without an id",
+			config: "bar {\n    position top\n}\n",
+			err:    ":1: bar has no id",
+		},
+		{
+			name:   "duplicate bar",
+			config: "bar {\n    id main\n}\nbar {\n    id main\n}\n",
+			err:    `:4: duplicate bar "main", first defined at`,
+		},
+		{
+			name:   "missing brace",
+			config: "bar {\n    id main\n    colors {\n    }\n",
+			err:    ":1: bar is missing its closing '}'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"i3bar.conf": tt.config})
+			defer os.RemoveAll(dir)
+			c, err := ParseI3barConfig(filepath.Join(dir, "i3bar.conf"))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, b := range c.Bars {
+				got = append(got, b.ID+":"+strings.TrimPrefix(b.Pos(), b.File+":"))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got bars %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestI3barGet(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"i3bar.conf": `bar {
+    id main
+    status_command   i3status -c ~/.i3status
+    tray_output none
+    workspace_buttons
+    colors {
+        background #000000
+    }
+}
+`})
+	defer os.RemoveAll(dir)
+	c, err := ParseI3barConfig(filepath.Join(dir, "i3bar.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, ok := c.Bar("main")
+	if !ok {
+		t.Fatal("bar main not found")
+	}
+	if _, ok := c.Bar("other"); ok {
+		t.Error("found bar other, which isn't defined")
+	}
+	tests := []struct {
+		key   string
+		value string
+		ok    bool
+	}{
+		{"status_command", "i3status -c ~/.i3status", true},
+		{"tray_output", "none", true},
+		{"workspace_buttons", "", true},
+		{"id", "", false},
+		{"background", "", false},
+		{"position", "", false},
+	}
+	for _, tt := range tests {
+		value, ok := b.Get(tt.key)
+		if value != tt.value || ok != tt.ok {
+			t.Errorf("Get(%q) = %q, %v, want %q, %v", tt.key, value, ok, tt.value, tt.ok)
+		}
+	}
+}
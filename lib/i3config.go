@@ -0,0 +1,153 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// I3Config is an i3 config with the files it includes.
+type I3Config struct {
+	Path string
+	// Files are the files that were read, in the order i3 reads them.
+	Files []string
+	// Lines are the lines of all files in the order i3 reads them, without
+	// comments and empty lines. Continued lines are joined.
+	Lines []I3ConfigLine
+}
+
+// I3ConfigLine is a line of an i3 config.
+type I3ConfigLine struct {
+	File string
+	Line int
+	Text string
+	// Depth is how many blocks, like 'mode "resize" {', the line is in.
+	Depth int
+}
+
+// Pos returns the position of the line as "file:line".
+func (l I3ConfigLine) Pos() string {
+	return fmt.Sprintf("%s:%d", l.File, l.Line)
+}
+
+// Fields returns the words of the line.
+func (l I3ConfigLine) Fields() []string {
+	return strings.Fields(l.Text)
+}
+
+// Directives returns the lines outside of blocks that start with name, e.g.
+// gaps.
+func (c *I3Config) Directives(name string) []I3ConfigLine {
+	var lines []I3ConfigLine
+	for _, l := range c.Lines {
+		if fields := l.Fields(); l.Depth == 0 && len(fields) > 0 && fields[0] == name {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+var i3VarRe = regexp.MustCompile(`\$\w+`)
+
+// ParseI3Config reads an i3 config and the files it includes. Like i3, include
+// paths can use ~, environment variables, variables defined with set and
+// wildcards, relative paths are relative to the including file, and no file
+// is read twice. Files an include doesn't match are skipped.
+func ParseI3Config(path string) (*I3Config, error) {
+	c := &I3Config{Path: path}
+	vars := map[string]string{}
+	if err := c.read(path, vars); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *I3Config) read(path string, vars map[string]string) error {
+	for _, f := range c.Files {
+		if f == path {
+			return nil
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	c.Files = append(c.Files, path)
+
+	scanner := bufio.NewScanner(f)
+	line, start, depth := 0, 0, 0
+	text := ""
+	for scanner.Scan() {
+		line++
+		part := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			start = line
+			if part == "" || strings.HasPrefix(part, "#") {
+				continue
+			}
+		}
+		if strings.HasSuffix(part, "\\") {
+			text += strings.TrimSuffix(part, "\\")
+			continue
+		}
+		text += part
+		l := I3ConfigLine{File: path, Line: start, Text: text, Depth: depth}
+		text = ""
+		depth += strings.Count(l.Text, "{") - strings.Count(l.Text, "}")
+		if depth < 0 {
+			depth = 0
+		}
+		fields := l.Fields()
+		if l.Depth == 0 && len(fields) >= 3 && fields[0] == "set" && strings.HasPrefix(fields[1], "$") {
+			vars[fields[1]] = strings.TrimSpace(strings.SplitN(l.Text, fields[1], 2)[1])
+		}
+		if l.Depth == 0 && len(fields) >= 2 && fields[0] == "include" {
+			pattern := strings.TrimSpace(strings.TrimPrefix(l.Text, "include"))
+			files, err := includedFiles(pattern, filepath.Dir(path), vars)
+			if err != nil {
+				return fmt.Errorf("%s: %s", l.Pos(), err)
+			}
+			for _, included := range files {
+				if err := c.read(included, vars); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		c.Lines = append(c.Lines, l)
+	}
+	return scanner.Err()
+}
+
+// includedFiles returns the files an include pattern matches, sorted by name.
+func includedFiles(pattern, dir string, vars map[string]string) ([]string, error) {
+	pattern = strings.Trim(pattern, `"'`)
+	pattern = i3VarRe.ReplaceAllStringFunc(pattern, func(name string) string {
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return os.Getenv(name[1:])
+	})
+	pattern = os.ExpandEnv(pattern)
+	if strings.HasPrefix(pattern, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		pattern = filepath.Join(home, pattern[2:])
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(dir, pattern)
+	}
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include \"%s\": %s", pattern, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
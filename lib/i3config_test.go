@@ -0,0 +1,184 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseI3Config(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		// env are set to directories, relative to the config.
+		env map[string]string
+		// want are the lines, as "file:line text", read the files in the order
+		// they are read.
+		want []string
+		read []string
+		err  string
+	}{
+		{
+			name:  "comments and empty lines are skipped",
+			files: map[string]string{"config": "# gaps\n\n  gaps inner 10\n"},
+			want:  []string{"config:3 gaps inner 10"},
+		},
+		{
+			name:  "continued lines are joined",
+			files: map[string]string{"config": "bindsym $mod+Return \\\n  exec \\\n  kitty\ngaps outer 5\n"},
+			want:  []string{"config:1 bindsym $mod+Return exec kitty", "config:4 gaps outer 5"},
+		},
+		{
+			name: "include relative to the including file",
+			files: map[string]string{
+				"config":           "gaps inner 1\ninclude conf.d/gaps.conf\ngaps inner 3\n",
+				"conf.d/gaps.conf": "gaps inner 2\n",
+			},
+			want: []string{"config:1 gaps inner 1", "conf.d/gaps.conf:1 gaps inner 2", "config:3 gaps inner 3"},
+			read: []string{"config", "conf.d/gaps.conf"},
+		},
+		{
+			name: "include globs are read in order of their names",
+			files: map[string]string{
+				"config":        "include \"conf.d/*.conf\"\n",
+				"conf.d/b.conf": "gaps inner 2\n",
+				"conf.d/a.conf": "gaps inner 1\n",
+				"conf.d/c.txt":  "gaps inner 3\n",
+			},
+			want: []string{"conf.d/a.conf:1 gaps inner 1", "conf.d/b.conf:1 gaps inner 2"},
+		},
+		{
+			name: "include with a variable",
+			files: map[string]string{
+				"config":     "set $dir parts\ninclude $dir/gaps\n",
+				"parts/gaps": "gaps top 4\n",
+			},
+			want: []string{"config:1 set $dir parts", "parts/gaps:1 gaps top 4"},
+		},
+		{
+			name: "include with an environment variable",
+			files: map[string]string{
+				"config":     "include ${DOT_TEST_PARTS}/gaps\ninclude $DOT_TEST_PARTS/more\n",
+				"parts/gaps": "gaps top 4\n",
+				"parts/more": "gaps top 5\n",
+			},
+			env:  map[string]string{"DOT_TEST_PARTS": "parts"},
+			want: []string{"parts/gaps:1 gaps top 4", "parts/more:1 gaps top 5"},
+		},
+		{
+			name: "include from home",
+			files: map[string]string{
+				"config":    "include ~/home.conf\n",
+				"home.conf": "gaps left 6\n",
+			},
+			env:  map[string]string{"HOME": "."},
+			want: []string{"home.conf:1 gaps left 6"},
+		},
+		{
+			name: "files are only read once",
+			files: map[string]string{
+				"config": "include a\ngaps inner 1\n",
+				"a":      "include config\ninclude a\ngaps inner 2\n",
+			},
+			want: []string{"a:3 gaps inner 2", "config:2 gaps inner 1"},
+			read: []string{"config", "a"},
+		},
+		{
+			name:  "includes that match nothing are skipped",
+			files: map[string]string{"config": "include nope/*.conf\ngaps inner 1\n"},
+			want:  []string{"config:2 gaps inner 1"},
+		},
+		{
+			name:  "invalid include pattern",
+			files: map[string]string{"config": "include [\n"},
+			err:   "invalid include",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			defer os.RemoveAll(dir)
+			for name, value := range tt.env {
+				// the variables are directories the config includes from
+				value = filepath.Join(dir, value)
+				old, ok := os.LookupEnv(name)
+				os.Setenv(name, value)
+				if ok {
+					defer os.Setenv(name, old)
+				} else {
+					defer os.Unsetenv(name)
+				}
+			}
+			c, err := ParseI3Config(filepath.Join(dir, "config"))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, l := range c.Lines {
+				rel, _ := filepath.Rel(dir, l.File)
+				got = append(got, rel+":"+strconv.Itoa(l.Line)+" "+l.Text)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got lines %q, want %q", got, tt.want)
+			}
+			if tt.read != nil {
+				var files []string
+				for _, f := range c.Files {
+					rel, _ := filepath.Rel(dir, f)
+					files = append(files, rel)
+				}
+				if !reflect.DeepEqual(files, tt.read) {
+					t.Errorf("got files %q, want %q", files, tt.read)
+				}
+			}
+		})
+	}
+}
+
+func TestI3ConfigDirectives(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"config": `gaps inner 10
+mode "resize" {
+    gaps inner 20
+    bindsym Escape mode "default"
+}
+bar {
+    colors {
+        background #000000
+    }
+}
+gaps outer 5
+`})
+	defer os.RemoveAll(dir)
+	c, err := ParseI3Config(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, l := range c.Directives("gaps") {
+		got = append(got, l.Text)
+	}
+	if want := []string{"gaps inner 10", "gaps outer 5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Directives(gaps) = %q, want %q", got, want)
+	}
+	depths := map[string]int{}
+	for _, l := range c.Lines {
+		depths[l.Text] = l.Depth
+	}
+	for text, want := range map[string]int{"gaps inner 20": 1, "background #000000": 2, "gaps outer 5": 0} {
+		if depths[text] != want {
+			t.Errorf("depth of %q = %d, want %d", text, depths[text], want)
+		}
+	}
+	if l := c.Directives("gaps")[1]; l.Pos() != filepath.Join(dir, "config")+":11" {
+		t.Errorf("Pos() = %s, want config:11", l.Pos())
+	}
+}
@@ -0,0 +1,407 @@
+package lib
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PolybarConfig is a parsed polybar config file, including every file it includes.
+type PolybarConfig struct {
+	Path     string
+	Sections map[string]*PolybarSection
+	// LookupEnv resolves ${env:NAME} references. Defaults to os.LookupEnv.
+	LookupEnv func(string) (string, bool)
+
+	order      []string
+	files      []string
+	searchDirs []string
+	included   map[string]bool
+}
+
+// PolybarSection is a [section] of a polybar config.
+type PolybarSection struct {
+	Name string
+	File string
+	Line int
+	Keys map[string]PolybarValue
+
+	order []string
+}
+
+// PolybarValue is the raw value of a key and where it was defined.
+type PolybarValue struct {
+	Key   string
+	Value string
+	File  string
+	Line  int
+}
+
+// Pos returns the position of the value as "file:line".
+func (v PolybarValue) Pos() string {
+	return fmt.Sprintf("%s:%d", v.File, v.Line)
+}
+
+// Pos returns the position of the section header as "file:line".
+func (s *PolybarSection) Pos() string {
+	return fmt.Sprintf("%s:%d", s.File, s.Line)
+}
+
+// OwnKeys returns the keys defined directly in the section, in file order.
+func (s *PolybarSection) OwnKeys() []string {
+	return s.order
+}
+
+var (
+	// example: [bar/SOME.BAR] -> bar/SOME.BAR
+	polybarSectionRe = regexp.MustCompile(`^\[([^\[\]]+)\]$`)
+	// example: ${colors.background:#222} -> colors.background:#222
+	polybarReferenceRe = regexp.MustCompile(`\$\{([^${}]+)\}`)
+)
+
+// ParsePolybarConfig parses the polybar config at path and every file it includes.
+// Includes that are not found relative to the including file are looked up in
+// searchDirs, which lets themes include files from the shared 'global' theme.
+func ParsePolybarConfig(path string, searchDirs ...string) (*PolybarConfig, error) {
+	c := &PolybarConfig{
+		Path:       path,
+		Sections:   map[string]*PolybarSection{},
+		LookupEnv:  os.LookupEnv,
+		searchDirs: searchDirs,
+		included:   map[string]bool{},
+	}
+	if err := c.parseFile(path); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *PolybarConfig) parseFile(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if c.included[abs] {
+		return fmt.Errorf("%s is included more than once", path)
+	}
+	c.included[abs] = true
+	c.files = append(c.files, path)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var section *PolybarSection
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, ";") || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			m := polybarSectionRe.FindStringSubmatch(text)
+			if m == nil {
+				return fmt.Errorf("%s:%d: invalid section header %q", path, line, text)
+			}
+			name := strings.TrimSpace(m[1])
+			if s, ok := c.Sections[name]; ok {
+				return fmt.Errorf("%s:%d: duplicate section [%s], first defined at %s", path, line, name, s.Pos())
+			}
+			section = &PolybarSection{Name: name, File: path, Line: line, Keys: map[string]PolybarValue{}}
+			c.Sections[name] = section
+			c.order = append(c.order, name)
+			continue
+		}
+
+		i := strings.Index(text, "=")
+		if i < 0 {
+			return fmt.Errorf("%s:%d: expected 'key = value', got %q", path, line, text)
+		}
+		key := strings.TrimSpace(text[:i])
+		value := unquote(strings.TrimSpace(text[i+1:]))
+		if key == "" {
+			return fmt.Errorf("%s:%d: missing key", path, line)
+		}
+
+		switch key {
+		case "include-file":
+			inc, err := c.findInclude(path, value)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %s", path, line, err)
+			}
+			if err := c.parseFile(inc); err != nil {
+				return err
+			}
+			continue
+		case "include-directory":
+			dir, err := c.findInclude(path, value)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %s", path, line, err)
+			}
+			files, err := ioutil.ReadDir(dir)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %s", path, line, err)
+			}
+			for _, inc := range files {
+				if inc.IsDir() {
+					continue
+				}
+				if err := c.parseFile(filepath.Join(dir, inc.Name())); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if section == nil {
+			return fmt.Errorf("%s:%d: key %q is outside of a section", path, line, key)
+		}
+		if v, ok := section.Keys[key]; ok {
+			return fmt.Errorf("%s:%d: duplicate key %q in [%s], first defined at %s", path, line, key, section.Name, v.Pos())
+		}
+		section.Keys[key] = PolybarValue{Key: key, Value: value, File: path, Line: line}
+		section.order = append(section.order, key)
+	}
+	return scanner.Err()
+}
+
+// findInclude resolves the path of an include relative to the including file,
+// falling back to the search dirs.
+func (c *PolybarConfig) findInclude(from, include string) (string, error) {
+	p := os.ExpandEnv(include)
+	if strings.HasPrefix(p, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		p = filepath.Join(home, p[2:])
+	}
+	if filepath.IsAbs(p) {
+		if _, err := os.Stat(p); err != nil {
+			return "", fmt.Errorf("include %q not found", include)
+		}
+		return p, nil
+	}
+	dirs := append([]string{filepath.Dir(from)}, c.searchDirs...)
+	for _, dir := range dirs {
+		candidate := filepath.Join(dir, p)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("include %q not found in %s", include, strings.Join(dirs, ", "))
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// Files returns the config file and every file it includes, in the order they were parsed.
+func (c *PolybarConfig) Files() []string {
+	return c.files
+}
+
+// Section returns the section with the given name, e.g. "bar/main".
+func (c *PolybarConfig) Section(name string) (*PolybarSection, bool) {
+	s, ok := c.Sections[name]
+	return s, ok
+}
+
+// SectionNames returns the names of all sections in the order they were parsed.
+func (c *PolybarConfig) SectionNames() []string {
+	return c.order
+}
+
+// Bars returns the names of the bars defined in the config, without the "bar/"
+// prefix, in the order they were defined.
+func (c *PolybarConfig) Bars() []string {
+	var bars []string
+	for _, name := range c.order {
+		if strings.HasPrefix(name, "bar/") {
+			bars = append(bars, strings.TrimPrefix(name, "bar/"))
+		}
+	}
+	return bars
+}
+
+// Lookup returns the raw value of key in section, following 'inherit'.
+// References in the value are not resolved.
+func (c *PolybarConfig) Lookup(section, key string) (PolybarValue, bool) {
+	return c.lookup(section, key, map[string]bool{})
+}
+
+func (c *PolybarConfig) lookup(section, key string, seen map[string]bool) (PolybarValue, bool) {
+	s, ok := c.Sections[section]
+	if !ok || seen[section] {
+		return PolybarValue{}, false
+	}
+	seen[section] = true
+	if v, ok := s.Keys[key]; ok {
+		return v, true
+	}
+	if inherit, ok := s.Keys["inherit"]; ok {
+		for _, parent := range strings.Fields(inherit.Value) {
+			if v, ok := c.lookup(parent, key, seen); ok {
+				return v, true
+			}
+		}
+	}
+	return PolybarValue{}, false
+}
+
+// Keys returns every key of section, including inherited keys, sorted.
+func (c *PolybarConfig) Keys(section string) []string {
+	keys := map[string]bool{}
+	c.collectKeys(section, keys, map[string]bool{})
+	var sorted []string
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+func (c *PolybarConfig) collectKeys(section string, keys, seen map[string]bool) {
+	s, ok := c.Sections[section]
+	if !ok || seen[section] {
+		return
+	}
+	seen[section] = true
+	for k := range s.Keys {
+		if k != "inherit" {
+			keys[k] = true
+		}
+	}
+	if inherit, ok := s.Keys["inherit"]; ok {
+		for _, parent := range strings.Fields(inherit.Value) {
+			c.collectKeys(parent, keys, seen)
+		}
+	}
+}
+
+// Inherits returns the sections named in the section's 'inherit' key.
+func (c *PolybarConfig) Inherits(section string) []string {
+	s, ok := c.Sections[section]
+	if !ok {
+		return nil
+	}
+	return strings.Fields(s.Keys["inherit"].Value)
+}
+
+// Get returns the value of key in section with inheritance and ${...}
+// references resolved.
+func (c *PolybarConfig) Get(section, key string) (string, error) {
+	return c.get(section, section, key, nil)
+}
+
+// GetDefault is like Get, but returns def if the key isn't set.
+func (c *PolybarConfig) GetDefault(section, key, def string) (string, error) {
+	if _, ok := c.Lookup(section, key); !ok {
+		return def, nil
+	}
+	return c.Get(section, key)
+}
+
+func (c *PolybarConfig) get(root, section, key string, stack []string) (string, error) {
+	ref := section + "." + key
+	for _, s := range stack {
+		if s == ref {
+			return "", fmt.Errorf("reference cycle: %s -> %s", strings.Join(stack, " -> "), ref)
+		}
+	}
+	v, ok := c.Lookup(section, key)
+	if !ok {
+		return "", fmt.Errorf("key %q is not defined in [%s]", key, section)
+	}
+	value, err := c.resolve(root, section, v.Value, append(stack, ref))
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", v.Pos(), err)
+	}
+	return value, nil
+}
+
+// resolve replaces every ${...} reference in value.
+func (c *PolybarConfig) resolve(root, self, value string, stack []string) (string, error) {
+	var firstErr error
+	resolved := polybarReferenceRe.ReplaceAllStringFunc(value, func(m string) string {
+		if firstErr != nil {
+			return m
+		}
+		v, err := c.resolveReference(root, self, m[2:len(m)-1], stack)
+		if err != nil {
+			firstErr = err
+		}
+		return v
+	})
+	return resolved, firstErr
+}
+
+func (c *PolybarConfig) resolveReference(root, self, ref string, stack []string) (string, error) {
+	// split off the fallback value, e.g. ${env:MONITOR:DP-1} or ${colors.bg:#000}
+	var fallback string
+	hasFallback := false
+	switch {
+	case strings.HasPrefix(ref, "env:"), strings.HasPrefix(ref, "xrdb:"), strings.HasPrefix(ref, "file:"):
+		parts := strings.SplitN(ref, ":", 3)
+		if len(parts) == 3 {
+			fallback, hasFallback = parts[2], true
+		}
+		ref = parts[0] + ":" + parts[1]
+	default:
+		if i := strings.Index(ref, ":"); i >= 0 {
+			fallback, hasFallback = ref[i+1:], true
+			ref = ref[:i]
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(ref, "env:"):
+		if v, ok := c.LookupEnv(strings.TrimPrefix(ref, "env:")); ok {
+			return v, nil
+		}
+		return fallback, nil
+	case strings.HasPrefix(ref, "xrdb:"):
+		// X resources can't be known ahead of time
+		return fallback, nil
+	case strings.HasPrefix(ref, "file:"):
+		data, err := ioutil.ReadFile(os.ExpandEnv(strings.TrimPrefix(ref, "file:")))
+		if err != nil {
+			if hasFallback {
+				return fallback, nil
+			}
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	i := strings.LastIndex(ref, ".")
+	if i < 0 {
+		return "", fmt.Errorf("invalid reference ${%s}", ref)
+	}
+	section, key := ref[:i], ref[i+1:]
+	switch section {
+	case "root":
+		section = root
+	case "self":
+		section = self
+	}
+	if _, ok := c.Lookup(section, key); !ok {
+		if hasFallback {
+			return fallback, nil
+		}
+		return "", fmt.Errorf("reference ${%s} is not defined", ref)
+	}
+	return c.get(root, section, key, stack)
+}
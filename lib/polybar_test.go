@@ -0,0 +1,241 @@
+package lib
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates the files, by path relative to a new directory, and
+// returns the directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "dot-lib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestParsePolybarConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		// env is what ${env:...} sees.
+		env      map[string]string
+		section  string
+		key      string
+		want     string
+		parseErr string
+		getErr   string
+	}{
+		{
+			name:    "value",
+			files:   map[string]string{"config": "[bar/main]\nheight = 30\n"},
+			section: "bar/main", key: "height", want: "30",
+		},
+		{
+			name:    "quoted value and comments",
+			files:   map[string]string{"config": "; comment\n# comment\n[bar/main]\nlabel = \" %title% \"\n"},
+			section: "bar/main", key: "label", want: " %title% ",
+		},
+		{
+			name: "include-file relative to the including file",
+			files: map[string]string{
+				"config":        "include-file = parts/colors\n[bar/main]\nbackground = ${colors.bg}\n",
+				"parts/colors":  "include-file = more\n",
+				"parts/more":    "[colors]\nbg = #222\n",
+				"unused/colors": "[colors]\nbg = #fff\n",
+			},
+			section: "bar/main", key: "background", want: "#222",
+		},
+		{
+			name: "include-file from a search dir",
+			files: map[string]string{
+				"theme/config":  "include-file = colors\n[bar/main]\nbackground = ${colors.bg}\n",
+				"global/colors": "[colors]\nbg = #333\n",
+			},
+			section: "bar/main", key: "background", want: "#333",
+		},
+		{
+			name: "include-directory",
+			files: map[string]string{
+				"config":      "include-directory = modules\n[bar/main]\nmodules-left = ${module/a.type} ${module/b.type}\n",
+				"modules/a":   "[module/a]\ntype = internal/date\n",
+				"modules/b":   "[module/b]\ntype = custom/script\n",
+				"modules/c/d": "this is skipped, it is in a directory\n",
+			},
+			section: "bar/main", key: "modules-left", want: "internal/date custom/script",
+		},
+		{
+			name: "include cycle",
+			files: map[string]string{
+				"config": "include-file = a\n",
+				"a":      "include-file = config\n",
+			},
+			parseErr: "included more than once",
+		},
+		{
+			name:     "missing include",
+			files:    map[string]string{"config": "include-file = nope\n"},
+			parseErr: `include "nope" not found`,
+		},
+		{
+			name:     "duplicate section",
+			files:    map[string]string{"config": "[bar/main]\n[bar/main]\n"},
+			parseErr: "duplicate section [bar/main]",
+		},
+		{
+			name:     "duplicate key",
+			files:    map[string]string{"config": "[bar/main]\nheight = 1\nheight = 2\n"},
+			parseErr: `duplicate key "height"`,
+		},
+		{
+			name:     "key outside of a section",
+			files:    map[string]string{"config": "height = 1\n"},
+			parseErr: "outside of a section",
+		},
+		{
+			name:     "not a key",
+			files:    map[string]string{"config": "[bar/main]\nheight\n"},
+			parseErr: "expected 'key = value'",
+		},
+		{
+			name:    "inherit",
+			files:   map[string]string{"config": "[bar/base]\nheight = 30\n[bar/main]\ninherit = bar/base\n"},
+			section: "bar/main", key: "height", want: "30",
+		},
+		{
+			name:    "inherit is overridden",
+			files:   map[string]string{"config": "[bar/base]\nheight = 30\n[bar/main]\ninherit = bar/base\nheight = 20\n"},
+			section: "bar/main", key: "height", want: "20",
+		},
+		{
+			name:    "inherit cycle",
+			files:   map[string]string{"config": "[bar/a]\ninherit = bar/b\n[bar/b]\ninherit = bar/a\n"},
+			section: "bar/a", key: "height", getErr: `key "height" is not defined`,
+		},
+		{
+			name:    "nested references",
+			files:   map[string]string{"config": "[colors]\nbg = ${colors.base}\nbase = #111\n[bar/main]\nbackground = ${colors.bg}\n"},
+			section: "bar/main", key: "background", want: "#111",
+		},
+		{
+			name:    "self and root",
+			files:   map[string]string{"config": "[bar/base]\nwidth = ${self.w}%\nheight = ${root.h}\n[bar/main]\ninherit = bar/base\nw = 100\nh = 30\nsize = ${self.width}x${self.height}\n"},
+			section: "bar/main", key: "size", want: "100%x30",
+		},
+		{
+			name:    "fallback",
+			files:   map[string]string{"config": "[bar/main]\nbackground = ${colors.nope:#000}\n"},
+			section: "bar/main", key: "background", want: "#000",
+		},
+		{
+			name:    "undefined reference",
+			files:   map[string]string{"config": "[bar/main]\nbackground = ${colors.nope}\n"},
+			section: "bar/main", key: "background", getErr: "reference ${colors.nope} is not defined",
+		},
+		{
+			name:    "reference cycle",
+			files:   map[string]string{"config": "[a]\nx = ${b.y}\n[b]\ny = ${a.x}\n"},
+			section: "a", key: "x", getErr: "reference cycle: a.x -> b.y -> a.x",
+		},
+		{
+			name:    "env",
+			files:   map[string]string{"config": "[bar/main]\nmonitor = ${env:MONITOR}\n"},
+			env:     map[string]string{"MONITOR": "DP-1"},
+			section: "bar/main", key: "monitor", want: "DP-1",
+		},
+		{
+			name:    "env fallback",
+			files:   map[string]string{"config": "[bar/main]\nmonitor = ${env:MONITOR:HDMI-0}\n"},
+			section: "bar/main", key: "monitor", want: "HDMI-0",
+		},
+		{
+			name:    "xrdb fallback",
+			files:   map[string]string{"config": "[colors]\nbg = ${xrdb:background:#222}\n"},
+			section: "colors", key: "bg", want: "#222",
+		},
+		{
+			name:    "invalid reference",
+			files:   map[string]string{"config": "[bar/main]\nbackground = ${nodot}\n"},
+			section: "bar/main", key: "background", getErr: "invalid reference ${nodot}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "config")
+			if _, ok := tt.files["theme/config"]; ok {
+				path = filepath.Join(dir, "theme", "config")
+			}
+			c, err := ParsePolybarConfig(path, filepath.Join(dir, "global"))
+			if tt.parseErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.parseErr) {
+					t.Fatalf("got error %v, want %q", err, tt.parseErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.LookupEnv = func(name string) (string, bool) {
+				v, ok := tt.env[name]
+				return v, ok
+			}
+			got, err := c.Get(tt.section, tt.key)
+			if tt.getErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.getErr) {
+					t.Fatalf("got %q, error %v, want error %q", got, err, tt.getErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolybarConfigBarsAndKeys(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"config": "[bar/base]\nheight = 30\n[module/date]\ntype = internal/date\n[bar/main]\ninherit = bar/base\nwidth = 100%\n",
+	})
+	defer os.RemoveAll(dir)
+	c, err := ParsePolybarConfig(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.Bars(), []string{"base", "main"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Bars() = %v, want %v", got, want)
+	}
+	if got, want := c.Keys("bar/main"), []string{"height", "width"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %v, want %v", got, want)
+	}
+	if got, want := c.Inherits("bar/main"), []string{"bar/base"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Inherits() = %v, want %v", got, want)
+	}
+	v, ok := c.Lookup("bar/main", "height")
+	if !ok || v.Line != 2 || v.File != filepath.Join(dir, "config") {
+		t.Errorf("Lookup() = %+v, %v, want line 2 of config", v, ok)
+	}
+	if got, err := c.GetDefault("bar/main", "bottom", "false"); err != nil || got != "false" {
+		t.Errorf("GetDefault() = %q, %v, want \"false\"", got, err)
+	}
+}
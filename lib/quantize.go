@@ -0,0 +1,141 @@
+package lib
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// quantizeSamples is about how many pixels of an image are looked at.
+const quantizeSamples = 20000
+
+// Swatch is a color of an image and the share of the image it covers.
+type Swatch struct {
+	Color  color.RGBA
+	Weight float64
+}
+
+// Quantize reduces an image to at most k colors using k-means, most common
+// first. The result is the same every time for the same image.
+func Quantize(img image.Image, k int) []Swatch {
+	b := img.Bounds()
+	step := int(math.Sqrt(float64(b.Dx()*b.Dy()) / quantizeSamples))
+	if step < 1 {
+		step = 1
+	}
+	var points [][3]float64
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		for x := b.Min.X; x < b.Max.X; x += step {
+			r, g, bl, a := img.At(x, y).RGBA()
+			// transparent pixels aren't seen
+			if a < 0x8000 {
+				continue
+			}
+			points = append(points, [3]float64{float64(r >> 8), float64(g >> 8), float64(bl >> 8)})
+		}
+	}
+	if len(points) == 0 || k < 1 {
+		return nil
+	}
+	if k > len(points) {
+		k = len(points)
+	}
+
+	centers := initCenters(points, k)
+	assign := make([]int, len(points))
+	for i := range assign {
+		assign[i] = -1
+	}
+	for iter := 0; iter < 30; iter++ {
+		moved := false
+		for i, p := range points {
+			if c := nearestCenter(centers, p); c != assign[i] {
+				assign[i] = c
+				moved = true
+			}
+		}
+		if !moved {
+			break
+		}
+		sums := make([][3]float64, k)
+		counts := make([]int, k)
+		for i, p := range points {
+			c := assign[i]
+			for j := range p {
+				sums[c][j] += p[j]
+			}
+			counts[c]++
+		}
+		for c := range centers {
+			if counts[c] == 0 {
+				continue
+			}
+			for j := range centers[c] {
+				centers[c][j] = sums[c][j] / float64(counts[c])
+			}
+		}
+	}
+
+	counts := make([]int, k)
+	for _, c := range assign {
+		counts[c]++
+	}
+	var swatches []Swatch
+	for c, center := range centers {
+		if counts[c] == 0 {
+			continue
+		}
+		swatches = append(swatches, Swatch{
+			Color:  color.RGBA{uint8(center[0] + 0.5), uint8(center[1] + 0.5), uint8(center[2] + 0.5), 0xff},
+			Weight: float64(counts[c]) / float64(len(points)),
+		})
+	}
+	sort.SliceStable(swatches, func(i, j int) bool { return swatches[i].Weight > swatches[j].Weight })
+	return swatches
+}
+
+// initCenters picks the first centers with k-means++: every next center is
+// picked with a chance that grows with its distance to the centers so far.
+func initCenters(points [][3]float64, k int) [][3]float64 {
+	rng := rand.New(rand.NewSource(1))
+	centers := [][3]float64{points[rng.Intn(len(points))]}
+	dist := make([]float64, len(points))
+	for len(centers) < k {
+		total := 0.0
+		for i, p := range points {
+			dist[i] = distance(p, centers[nearestCenter(centers, p)])
+			total += dist[i]
+		}
+		// every point is a center already
+		if total == 0 {
+			break
+		}
+		target := rng.Float64() * total
+		for i, d := range dist {
+			target -= d
+			if target <= 0 {
+				centers = append(centers, points[i])
+				break
+			}
+		}
+	}
+	return centers
+}
+
+func nearestCenter(centers [][3]float64, p [3]float64) int {
+	best, bestDist := 0, math.MaxFloat64
+	for i, c := range centers {
+		if d := distance(p, c); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// distance is the squared distance of two colors.
+func distance(a, b [3]float64) float64 {
+	dr, dg, db := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dr*dr + dg*dg + db*db
+}
@@ -0,0 +1,120 @@
+package lib
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+// stripes returns an image with a vertical stripe of every color, each as
+// wide as its width.
+func stripes(colors []color.Color, widths []int) image.Image {
+	total := 0
+	for _, w := range widths {
+		total += w
+	}
+	img := image.NewRGBA(image.Rect(0, 0, total, 10))
+	x := 0
+	for i, c := range colors {
+		for ; x < total && widths[i] > 0; x++ {
+			for y := 0; y < 10; y++ {
+				img.Set(x, y, c)
+			}
+			widths[i]--
+		}
+	}
+	return img
+}
+
+var (
+	red   = color.RGBA{0xff, 0, 0, 0xff}
+	blue  = color.RGBA{0, 0, 0xff, 0xff}
+	white = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	clear = color.RGBA{}
+)
+
+func TestQuantize(t *testing.T) {
+	tests := []struct {
+		name string
+		img  image.Image
+		k    int
+		want []Swatch
+	}{
+		{
+			name: "one color",
+			img:  stripes([]color.Color{red}, []int{10}),
+			k:    3,
+			want: []Swatch{{red, 1}},
+		},
+		{
+			name: "most common first",
+			img:  stripes([]color.Color{blue, red}, []int{3, 7}),
+			k:    2,
+			want: []Swatch{{red, 0.7}, {blue, 0.3}},
+		},
+		{
+			name: "three colors",
+			img:  stripes([]color.Color{white, blue, red}, []int{2, 3, 5}),
+			k:    3,
+			want: []Swatch{{red, 0.5}, {blue, 0.3}, {white, 0.2}},
+		},
+		{
+			name: "fewer colors than asked for",
+			img:  stripes([]color.Color{blue, red}, []int{5, 5}),
+			k:    5,
+			want: []Swatch{{blue, 0.5}, {red, 0.5}},
+		},
+		{
+			name: "transparent pixels are skipped",
+			img:  stripes([]color.Color{clear, red}, []int{5, 5}),
+			k:    2,
+			want: []Swatch{{red, 1}},
+		},
+		{
+			name: "transparent image",
+			img:  stripes([]color.Color{clear}, []int{10}),
+			k:    2,
+		},
+		{
+			name: "no colors asked for",
+			img:  stripes([]color.Color{red}, []int{10}),
+			k:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Quantize(tt.img, tt.k)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Color != tt.want[i].Color || !near(got[i].Weight, tt.want[i].Weight) {
+					t.Errorf("swatch %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestQuantizeIsDeterministic(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 64; y++ {
+			img.Set(x, y, color.RGBA{uint8(x * 4), uint8(y * 4), uint8((x + y) * 2), 0xff})
+		}
+	}
+	first := Quantize(img, 8)
+	if len(first) != 8 {
+		t.Fatalf("got %d swatches, want 8", len(first))
+	}
+	for i := 0; i < 3; i++ {
+		if again := Quantize(img, 8); !reflect.DeepEqual(first, again) {
+			t.Fatalf("got %v, then %v", first, again)
+		}
+	}
+}
+
+func near(a, b float64) bool {
+	return a-b < 1e-9 && b-a < 1e-9
+}
@@ -0,0 +1,42 @@
+package lib
+
+import (
+	"math"
+	"time"
+)
+
+// Sun returns when the sun rises and sets on the day of date at the given
+// latitude and longitude (degrees, north and east are positive), in date's
+// location. ok is false if the sun doesn't rise or set that day, near the poles.
+// It follows the sunrise equation, which is accurate to about a minute.
+func Sun(date time.Time, latitude, longitude float64) (sunrise, sunset time.Time, ok bool) {
+	rad := math.Pi / 180
+	// days since the J2000 epoch at noon of the date
+	noon := time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, time.UTC)
+	n := math.Round(julianDay(noon) - 2451545.0 + 0.0008)
+	// mean solar time at the longitude
+	mean := n - longitude/360
+	anomaly := math.Mod(357.5291+0.98560028*mean, 360)
+	center := 1.9148*math.Sin(anomaly*rad) + 0.02*math.Sin(2*anomaly*rad) + 0.0003*math.Sin(3*anomaly*rad)
+	ecliptic := math.Mod(anomaly+center+180+102.9372, 360)
+	transit := 2451545.0 + mean + 0.0053*math.Sin(anomaly*rad) - 0.0069*math.Sin(2*ecliptic*rad)
+	declination := math.Asin(math.Sin(ecliptic*rad) * math.Sin(23.4397*rad))
+	// -0.833° accounts for refraction and the size of the sun's disc
+	cosHour := (math.Sin(-0.833*rad) - math.Sin(latitude*rad)*math.Sin(declination)) /
+		(math.Cos(latitude*rad) * math.Cos(declination))
+	if cosHour < -1 || cosHour > 1 {
+		return time.Time{}, time.Time{}, false
+	}
+	hour := math.Acos(cosHour) / rad
+	sunrise = fromJulianDay(transit - hour/360).In(date.Location())
+	sunset = fromJulianDay(transit + hour/360).In(date.Location())
+	return sunrise, sunset, true
+}
+
+func julianDay(t time.Time) float64 {
+	return float64(t.Unix())/86400 + 2440587.5
+}
+
+func fromJulianDay(jd float64) time.Time {
+	return time.Unix(int64(math.Round((jd-2440587.5)*86400)), 0)
+}
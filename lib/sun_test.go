@@ -0,0 +1,58 @@
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSun(t *testing.T) {
+	tests := []struct {
+		name           string
+		latitude       float64
+		longitude      float64
+		date           string
+		utcOffset      int
+		sunrise        string
+		sunset         string
+		noSunriseOrSet bool
+	}{
+		{name: "Berlin, summer solstice", latitude: 52.52, longitude: 13.405, date: "2024-06-21", utcOffset: 2, sunrise: "04:43", sunset: "21:33"},
+		{name: "London, winter solstice", latitude: 51.5074, longitude: -0.1278, date: "2024-12-21", sunrise: "08:04", sunset: "15:53"},
+		{name: "Sydney, southern summer", latitude: -33.8688, longitude: 151.2093, date: "2024-12-21", utcOffset: 11, sunrise: "05:41", sunset: "20:05"},
+		{name: "New York, equinox", latitude: 40.7128, longitude: -74.006, date: "2024-03-20", utcOffset: -4, sunrise: "06:58", sunset: "19:08"},
+		{name: "Tromsø, midnight sun", latitude: 69.6492, longitude: 18.9553, date: "2024-06-21", utcOffset: 2, noSunriseOrSet: true},
+		{name: "Tromsø, polar night", latitude: 69.6492, longitude: 18.9553, date: "2024-12-21", utcOffset: 1, noSunriseOrSet: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := time.FixedZone(tt.name, tt.utcOffset*3600)
+			day, err := time.ParseInLocation("2006-01-02", tt.date, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sunrise, sunset, ok := Sun(day.Add(12*time.Hour), tt.latitude, tt.longitude)
+			if ok == tt.noSunriseOrSet {
+				t.Fatalf("got ok %v, want %v", ok, !tt.noSunriseOrSet)
+			}
+			if !ok {
+				return
+			}
+			for _, c := range []struct {
+				event string
+				got   time.Time
+				want  string
+			}{{"sunrise", sunrise, tt.sunrise}, {"sunset", sunset, tt.sunset}} {
+				want, err := time.ParseInLocation("2006-01-02 15:04", tt.date+" "+c.want, loc)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if d := c.got.Sub(want); d < -time.Minute || d > time.Minute {
+					t.Errorf("%s at %s, want %s", c.event, c.got.Format("15:04:05"), c.want)
+				}
+				if c.got.Location() != loc {
+					t.Errorf("%s is in %s, want %s", c.event, c.got.Location(), loc)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package lib
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Ways to draw a wallpaper on an output.
+const (
+	// WallpaperFill scales the image to cover the output, cutting off the edges.
+	WallpaperFill = "fill"
+	// WallpaperFit scales the image to fit on the output, with black bars.
+	WallpaperFit = "fit"
+	// WallpaperCenter centers the image without scaling it.
+	WallpaperCenter = "center"
+	// WallpaperTile repeats the image from the top left corner of the output.
+	WallpaperTile = "tile"
+)
+
+// WallpaperModes are the ways to draw a wallpaper.
+var WallpaperModes = []string{WallpaperFill, WallpaperFit, WallpaperCenter, WallpaperTile}
+
+// DrawWallpaper draws img on the part r of dst.
+func DrawWallpaper(dst *image.RGBA, r image.Rectangle, img image.Image, mode string) error {
+	src := toRGBA(img)
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if sw == 0 || sh == 0 || r.Empty() {
+		return nil
+	}
+	switch mode {
+	case WallpaperTile:
+		for y := r.Min.Y; y < r.Max.Y; y += sh {
+			for x := r.Min.X; x < r.Max.X; x += sw {
+				draw.Draw(dst, image.Rect(x, y, x+sw, y+sh).Intersect(r), src, src.Bounds().Min, draw.Src)
+			}
+		}
+		return nil
+	case WallpaperFill, WallpaperFit, WallpaperCenter, "":
+	default:
+		return fmt.Errorf("unknown wallpaper mode \"%s\", expected one of: %v", mode, WallpaperModes)
+	}
+
+	scale := 1.0
+	sx, sy := float64(r.Dx())/float64(sw), float64(r.Dy())/float64(sh)
+	switch mode {
+	case WallpaperFit:
+		scale = math.Min(sx, sy)
+	case WallpaperFill, "":
+		scale = math.Max(sx, sy)
+	}
+	if scale != 1 {
+		src = scaleRGBA(src, int(float64(sw)*scale+0.5), int(float64(sh)*scale+0.5))
+	}
+	draw.Draw(dst, r, image.NewUniform(color.Black), image.Point{}, draw.Src)
+	size := src.Bounds().Size()
+	offset := r.Min.Add(image.Pt((r.Dx()-size.X)/2, (r.Dy()-size.Y)/2))
+	target := image.Rectangle{offset, offset.Add(size)}.Intersect(r)
+	draw.Draw(dst, target, src, src.Bounds().Min.Add(target.Min.Sub(offset)), draw.Src)
+	return nil
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba
+}
+
+// scaleRGBA resizes src to w x h with bilinear filtering. Images are made at
+// most half as big in each step, so big images don't lose detail.
+func scaleRGBA(src *image.RGBA, w, h int) *image.RGBA {
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	for src.Bounds().Dx() > 2*w || src.Bounds().Dy() > 2*h {
+		hw, hh := src.Bounds().Dx(), src.Bounds().Dy()
+		if hw > 2*w {
+			hw = (hw + 1) / 2
+		}
+		if hh > 2*h {
+			hh = (hh + 1) / 2
+		}
+		src = bilinear(src, hw, hh)
+	}
+	return bilinear(src, w, h)
+}
+
+func bilinear(src *image.RGBA, w, h int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	for y := 0; y < h; y++ {
+		y0, y1, ty := sampleAt(y, h, sh)
+		for x := 0; x < w; x++ {
+			x0, x1, tx := sampleAt(x, w, sw)
+			p00 := src.PixOffset(b.Min.X+x0, b.Min.Y+y0)
+			p10 := src.PixOffset(b.Min.X+x1, b.Min.Y+y0)
+			p01 := src.PixOffset(b.Min.X+x0, b.Min.Y+y1)
+			p11 := src.PixOffset(b.Min.X+x1, b.Min.Y+y1)
+			d := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				top := float64(src.Pix[p00+c])*(1-tx) + float64(src.Pix[p10+c])*tx
+				bottom := float64(src.Pix[p01+c])*(1-tx) + float64(src.Pix[p11+c])*tx
+				dst.Pix[d+c] = uint8(top*(1-ty) + bottom*ty + 0.5)
+			}
+		}
+	}
+	return dst
+}
+
+// sampleAt returns the two source pixels around the center of pixel i of n,
+// when the source has size pixels, and how far between them the center lies.
+func sampleAt(i, n, size int) (int, int, float64) {
+	f := (float64(i)+0.5)*float64(size)/float64(n) - 0.5
+	if f < 0 {
+		f = 0
+	}
+	i0 := int(f)
+	if i0 >= size-1 {
+		return size - 1, size - 1, 0
+	}
+	return i0, i0 + 1, f - float64(i0)
+}
@@ -0,0 +1,95 @@
+package lib
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// solid returns an image of one color.
+func solid(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func TestDrawWallpaper(t *testing.T) {
+	black := color.RGBA{0, 0, 0, 0xff}
+	// the output is the right part of a 300x200 screen
+	output := image.Rect(100, 50, 300, 150)
+	tests := []struct {
+		name string
+		w, h int
+		mode string
+		// want is where the image ends up, the rest of the output is black
+		want image.Rectangle
+	}{
+		{"fill", 100, 100, WallpaperFill, output},
+		{"fill is the default", 100, 100, "", output},
+		{"fill a wide image", 400, 100, WallpaperFill, output},
+		{"fit", 100, 100, WallpaperFit, image.Rect(150, 50, 250, 150)},
+		{"fit a wide image", 400, 100, WallpaperFit, image.Rect(100, 75, 300, 125)},
+		{"fit a small image", 20, 20, WallpaperFit, image.Rect(150, 50, 250, 150)},
+		{"center", 50, 40, WallpaperCenter, image.Rect(175, 80, 225, 120)},
+		{"center a big image", 400, 400, WallpaperCenter, output},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			screen := solid(300, 200, blue)
+			if err := DrawWallpaper(screen, output, solid(tt.w, tt.h, red), tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			for y := 0; y < 200; y++ {
+				for x := 0; x < 300; x++ {
+					p := image.Pt(x, y)
+					want := blue
+					if p.In(tt.want) {
+						want = red
+					} else if p.In(output) {
+						want = black
+					}
+					if got := screen.RGBAAt(x, y); got != want {
+						t.Fatalf("pixel %v is %v, want %v", p, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDrawWallpaperTile(t *testing.T) {
+	output := image.Rect(100, 50, 300, 150)
+	// the top left pixel of each tile is white
+	tile := solid(60, 30, red)
+	tile.Set(0, 0, white)
+	screen := solid(300, 200, blue)
+	if err := DrawWallpaper(screen, output, tile, WallpaperTile); err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 300; x++ {
+			p := image.Pt(x, y)
+			want := blue
+			if p.In(output) {
+				want = red
+				if (x-output.Min.X)%60 == 0 && (y-output.Min.Y)%30 == 0 {
+					want = white
+				}
+			}
+			if got := screen.RGBAAt(x, y); got != want {
+				t.Fatalf("pixel %v is %v, want %v", p, got, want)
+			}
+		}
+	}
+}
+
+func TestDrawWallpaperMode(t *testing.T) {
+	screen := solid(10, 10, blue)
+	if err := DrawWallpaper(screen, screen.Bounds(), solid(5, 5, red), "stretch"); err == nil {
+		t.Error("got no error for mode stretch")
+	}
+	if got := screen.RGBAAt(0, 0); got != blue {
+		t.Errorf("an unknown mode drew %v", got)
+	}
+}